@@ -0,0 +1,27 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+
+	"github.com/fterrag/simple-sqsd/cron_worker"
+	log "github.com/sirupsen/logrus"
+)
+
+// serveAdmin serves metrics and other operational endpoints on addr, which should not be reachable from
+// outside the host or pod. The cron admin API is served when cronDaemon is set.
+func serveAdmin(addr string, cronDaemon *cron_worker.Worker) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	if cronDaemon != nil {
+		mux.Handle("/cron/entries", cronDaemon.Handler())
+		mux.Handle("/cron/entries/", cronDaemon.Handler())
+	}
+
+	log.Infof("Serving admin endpoints on %s", addr)
+
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Fatalf("Error while serving admin endpoints: %s", err)
+	}
+}
@@ -1,21 +1,32 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/fterrag/simple-sqsd/cron_worker"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/fterrag/simple-sqsd/oauth"
+	"github.com/fterrag/simple-sqsd/secret"
+	"github.com/fterrag/simple-sqsd/sqsdpb"
 	"github.com/fterrag/simple-sqsd/supervisor"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 type config struct {
@@ -26,14 +37,22 @@ type config struct {
 
 	HTTPMaxConns    int
 	HTTPURL         string
+	HTTPSocket      string
 	HTTPContentType string
 	HTTPTimeout     int
 
 	AWSEndpoint                 string
 	HTTPHMACHeader              string
 	HTTPAUTHORIZATIONHeader     string
+	HTTPAUTHORIZATIONHeaderFile string
 	HTTPAUTHORIZATIONHeaderName string
 	HMACSecretKey               []byte
+	HMACSecretKeyFile           string
+
+	OAuth2TokenURL     string
+	OAuth2ClientID     string
+	OAuth2ClientSecret string
+	OAuth2Scopes       []string
 
 	HTTPHealthPath        string
 	HTTPHealthWait        int
@@ -43,14 +62,64 @@ type config struct {
 	SQSHTTPTimeout int
 	SSLVerify      bool
 
+	TLSCAFile     string
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSMinVersion string
+	TLSMaxVersion string
+	TLSServerName string
+
 	CronFile     string
 	CronEndPoint string
 	CronTimeout  int
+	CronEnqueue  bool
+
+	CronLeaderLockFile string
+	CronLeaderTable    string
+	CronLeaderName     string
+	CronLeaderLease    int
+	DynamoDBEndpoint   string
+
+	CronStateFile     string
+	CronStateTable    string
+	CronCatchupWindow int
 
 	UserAgent string
+
+	UnwrapSNS         bool
+	UnwrapEventBridge bool
+	UnwrapS3Events    bool
+
+	ExtendedPayloads       bool
+	ExtendedPayloadsDelete bool
+	S3Endpoint             string
+	S3ForcePathStyle       bool
+
+	BatchDelivery bool
+	PayloadFormat string
+
+	CloudEventsTypeAttribute string
+	CloudEventsDefaultType   string
+
+	ExecCommand []string
+	ExecTimeout int
+
+	FastCGIAddress        string
+	FastCGIScriptFilename string
+	FastCGIRequestURI     string
+
+	GRPCAddress  string
+	GRPCInsecure bool
+
+	DeadLetterQueueURL string
+
+	AdminAddress string
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate-cron" {
+		os.Exit(validateCron(os.Args[2:], os.Stdout, os.Stderr))
+	}
 
 	c := &config{}
 
@@ -75,14 +144,74 @@ func main() {
 	c.HTTPAUTHORIZATIONHeader = os.Getenv("SQSD_HTTP_AUTHORIZATION_HEADER")
 	c.HTTPAUTHORIZATIONHeaderName = os.Getenv("SQSD_HTTP_AUTHORIZATION_HEADER_NAME")
 	c.HMACSecretKey = []byte(os.Getenv("SQSD_HMAC_SECRET_KEY"))
+	c.HTTPAUTHORIZATIONHeaderFile = os.Getenv("SQSD_HTTP_AUTHORIZATION_HEADER_FILE")
+	c.HMACSecretKeyFile = os.Getenv("SQSD_HMAC_SECRET_KEY_FILE")
+
+	c.OAuth2TokenURL = os.Getenv("SQSD_OAUTH2_TOKEN_URL")
+	c.OAuth2ClientID = os.Getenv("SQSD_OAUTH2_CLIENT_ID")
+	c.OAuth2ClientSecret = os.Getenv("SQSD_OAUTH2_CLIENT_SECRET")
+	c.OAuth2Scopes = strings.Fields(os.Getenv("SQSD_OAUTH2_SCOPES"))
 
 	c.SQSHTTPTimeout = getEnvInt("SQSD_SQS_HTTP_TIMEOUT", 15)
 	c.SSLVerify = getenvBool("SQSD_HTTP_SSL_VERIFY", true)
 
+	c.TLSCAFile = os.Getenv("SQSD_HTTP_TLS_CA_FILE")
+	c.TLSCertFile = os.Getenv("SQSD_HTTP_TLS_CERT_FILE")
+	c.TLSKeyFile = os.Getenv("SQSD_HTTP_TLS_KEY_FILE")
+	c.TLSMinVersion = os.Getenv("SQSD_HTTP_TLS_MIN_VERSION")
+	c.TLSMaxVersion = os.Getenv("SQSD_HTTP_TLS_MAX_VERSION")
+	c.TLSServerName = os.Getenv("SQSD_HTTP_TLS_SERVER_NAME")
+
 	c.CronFile = os.Getenv("SQSD_CRON_FILE")
 	c.CronEndPoint = os.Getenv("SQSD_CRON_ENDPOINT")
 	c.CronTimeout = getEnvInt("SQSD_CRON_TIMEOUT", 15)
+	c.CronEnqueue = getenvBool("SQSD_CRON_ENQUEUE", false)
+
+	c.CronLeaderLockFile = os.Getenv("SQSD_CRON_LEADER_LOCK_FILE")
+	c.CronLeaderTable = os.Getenv("SQSD_CRON_LEADER_TABLE")
+	c.CronLeaderName = os.Getenv("SQSD_CRON_LEADER_NAME")
+	if len(c.CronLeaderName) == 0 {
+		c.CronLeaderName = "simple-sqsd-cron"
+	}
+	c.CronLeaderLease = getEnvInt("SQSD_CRON_LEADER_LEASE", 15)
+	c.DynamoDBEndpoint = os.Getenv("SQSD_DYNAMODB_ENDPOINT")
+
+	c.CronStateFile = os.Getenv("SQSD_CRON_STATE_FILE")
+	c.CronStateTable = os.Getenv("SQSD_CRON_STATE_TABLE")
+	c.CronCatchupWindow = getEnvInt("SQSD_CRON_CATCHUP_WINDOW", 3600)
 
+	c.UnwrapSNS = getenvBool("SQSD_UNWRAP_SNS", false)
+	c.UnwrapEventBridge = getenvBool("SQSD_UNWRAP_EVENTBRIDGE", false)
+	c.UnwrapS3Events = getenvBool("SQSD_UNWRAP_S3_EVENTS", false)
+
+	c.ExtendedPayloads = getenvBool("SQSD_EXTENDED_PAYLOADS", false)
+	c.ExtendedPayloadsDelete = getenvBool("SQSD_EXTENDED_PAYLOADS_DELETE", false)
+	c.S3Endpoint = os.Getenv("SQSD_S3_ENDPOINT")
+	c.S3ForcePathStyle = getenvBool("SQSD_S3_FORCE_PATH_STYLE", false)
+
+	c.BatchDelivery = getenvBool("SQSD_BATCH_DELIVERY", false)
+	c.PayloadFormat = os.Getenv("SQSD_PAYLOAD_FORMAT")
+
+	c.CloudEventsTypeAttribute = os.Getenv("SQSD_CLOUDEVENTS_TYPE_ATTRIBUTE")
+	c.CloudEventsDefaultType = os.Getenv("SQSD_CLOUDEVENTS_DEFAULT_TYPE")
+
+	execCommand, err := splitCommand(os.Getenv("SQSD_EXEC_COMMAND"))
+	if err != nil {
+		log.Fatalf("Error while parsing SQSD_EXEC_COMMAND: %s", err)
+	}
+	c.ExecCommand = execCommand
+	c.ExecTimeout = getEnvInt("SQSD_EXEC_TIMEOUT", 15)
+
+	c.FastCGIAddress = os.Getenv("SQSD_FASTCGI_ADDRESS")
+	c.FastCGIScriptFilename = os.Getenv("SQSD_FASTCGI_SCRIPT_FILENAME")
+	c.FastCGIRequestURI = os.Getenv("SQSD_FASTCGI_REQUEST_URI")
+
+	c.GRPCAddress = os.Getenv("SQSD_GRPC_ADDRESS")
+	c.GRPCInsecure = getenvBool("SQSD_GRPC_INSECURE", false)
+
+	c.DeadLetterQueueURL = os.Getenv("SQSD_DEAD_LETTER_QUEUE_URL")
+
+	c.AdminAddress = os.Getenv("SQSD_ADMIN_ADDRESS")
 
 	if len(c.QueueRegion) == 0 {
 		log.Fatal("SQSD_QUEUE_REGION cannot be empty")
@@ -92,10 +221,34 @@ func main() {
 		log.Fatal("SQSD_QUEUE_URL cannot be empty")
 	}
 
-	if len(c.HTTPURL) == 0 {
+	if len(c.HTTPURL) == 0 && len(c.ExecCommand) == 0 && len(c.FastCGIAddress) == 0 && len(c.GRPCAddress) == 0 {
 		log.Fatal("SQSD_HTTP_URL cannot be empty")
 	}
 
+	if len(c.FastCGIAddress) > 0 && len(c.FastCGIScriptFilename) == 0 {
+		log.Fatal("SQSD_FASTCGI_SCRIPT_FILENAME cannot be empty")
+	}
+
+	if len(c.GRPCAddress) > 0 && c.BatchDelivery {
+		log.Fatal("SQSD_BATCH_DELIVERY cannot be used with SQSD_GRPC_ADDRESS")
+	}
+
+	if socketPath, httpURL, ok := parseUnixSocketURL(c.HTTPURL); ok {
+		c.HTTPSocket = socketPath
+		c.HTTPURL = httpURL
+	}
+
+	if len(c.PayloadFormat) == 0 {
+		c.PayloadFormat = string(supervisor.PayloadFormatRaw)
+	}
+
+	switch supervisor.PayloadFormat(c.PayloadFormat) {
+	case supervisor.PayloadFormatRaw, supervisor.PayloadFormatLambda,
+		supervisor.PayloadFormatCloudEventsBinary, supervisor.PayloadFormatCloudEventsStructured:
+	default:
+		log.Fatalf("SQSD_PAYLOAD_FORMAT has an unknown value: %s", c.PayloadFormat)
+	}
+
 	log.SetFormatter(&log.JSONFormatter{})
 
 	logLevel := os.Getenv("LOG_LEVEL")
@@ -115,13 +268,25 @@ func main() {
 		"httpPath":     c.HTTPURL,
 	})
 
-	if len(c.HTTPHealthPath) != 0 {
+	tlsConfig, err := newTLSConfig(c)
+	if err != nil {
+		log.Fatalf("Error while configuring TLS: %s", err)
+	}
+
+	backendTransport := newBackendTransport(c, tlsConfig)
+
+	if len(c.HTTPHealthPath) != 0 && len(c.HTTPURL) != 0 {
 		numSuccesses := 0
 		healthURL := fmt.Sprintf("%s%s", c.HTTPURL, c.HTTPHealthPath)
 		log.Infof("Waiting %d seconds before staring health check at '%s'", c.HTTPHealthWait, healthURL)
 		time.Sleep(time.Duration(c.HTTPHealthWait) * time.Second)
+		healthClient := &http.Client{
+			Transport: backendTransport,
+			Timeout:   time.Duration(c.HTTPTimeout) * time.Second,
+		}
 		for {
-			if resp, err := http.Get(healthURL); err == nil {
+			if resp, err := healthClient.Get(healthURL); err == nil {
+				resp.Body.Close()
 				log.Infof("%#v", resp)
 				if numSuccesses == c.HTTPHealthSucessCount {
 					break
@@ -158,6 +323,7 @@ func main() {
 	sqsSvc := sqs.New(awsSess, sqsConfig)
 
 	wConf := supervisor.WorkerConfig{
+		QueueRegion:      c.QueueRegion,
 		QueueURL:         c.QueueURL,
 		QueueMaxMessages: c.QueueMaxMessages,
 		QueueWaitTime:    c.QueueWaitTime,
@@ -171,19 +337,68 @@ func main() {
 		HTTPHMACHeader: c.HTTPHMACHeader,
 		HMACSecretKey:  c.HMACSecretKey,
 
+		HMACSecretKeyFile:           watchSecretFile(c.HMACSecretKeyFile),
+		HTTPAUTHORIZATIONHeaderFile: watchSecretFile(c.HTTPAUTHORIZATIONHeaderFile),
+
 		UserAgent: c.UserAgent,
+
+		EnvelopeDecoders: envelopeDecoders(c),
+
+		DeletePayloadsFromS3: c.ExtendedPayloadsDelete,
+
+		BatchDelivery: c.BatchDelivery,
+		PayloadFormat: supervisor.PayloadFormat(c.PayloadFormat),
+
+		CloudEventsTypeAttribute: c.CloudEventsTypeAttribute,
+		CloudEventsDefaultType:   c.CloudEventsDefaultType,
+
+		ExecCommand: c.ExecCommand,
+		ExecTimeout: time.Duration(c.ExecTimeout) * time.Second,
+
+		FastCGIAddress:        c.FastCGIAddress,
+		FastCGIScriptFilename: c.FastCGIScriptFilename,
+		FastCGIRequestURI:     c.FastCGIRequestURI,
+		FastCGITimeout:        time.Duration(c.HTTPTimeout) * time.Second,
+
+		GRPCTimeout: time.Duration(c.HTTPTimeout) * time.Second,
+
+		DeadLetterQueueURL: c.DeadLetterQueueURL,
+	}
+
+	if len(c.OAuth2TokenURL) > 0 {
+		tokenClient := &http.Client{
+			Transport: newTokenTransport(tlsConfig),
+			Timeout:   time.Duration(c.HTTPTimeout) * time.Second,
+		}
+
+		wConf.TokenSource = oauth.NewClientCredentials(tokenClient, c.OAuth2TokenURL, c.OAuth2ClientID, c.OAuth2ClientSecret, c.OAuth2Scopes)
+	}
+
+	if len(c.GRPCAddress) > 0 {
+		conn, err := grpc.Dial(c.GRPCAddress, grpc.WithTransportCredentials(grpcCredentials(c, tlsConfig)))
+		if err != nil {
+			log.Fatalf("Error while connecting to SQSD_GRPC_ADDRESS: %s", err)
+		}
+		defer conn.Close()
+
+		wConf.GRPCClient = sqsdpb.NewWorkerClient(conn)
+	}
+
+	if c.ExtendedPayloads {
+		s3Config := aws.NewConfig().
+			WithRegion(c.QueueRegion).
+			WithS3ForcePathStyle(c.S3ForcePathStyle)
+
+		if len(c.S3Endpoint) > 0 {
+			s3Config.WithEndpoint(c.S3Endpoint)
+		}
+
+		wConf.PayloadS3 = s3.New(awsSess, s3Config)
 	}
 
 	httpClient := &http.Client{
-		Transport: &http.Transport{
-			MaxIdleConns:        c.HTTPMaxConns,
-			MaxIdleConnsPerHost: c.HTTPMaxConns,
-			TLSClientConfig: &tls.Config{
-				MaxVersion:         tls.VersionTLS11,
-				InsecureSkipVerify: !c.SSLVerify,
-			},
-		},
-		Timeout: time.Duration(c.HTTPTimeout) * time.Second,
+		Transport: backendTransport,
+		Timeout:   time.Duration(c.HTTPTimeout) * time.Second,
 	}
 
 	if "" == c.CronEndPoint {
@@ -198,23 +413,242 @@ func main() {
 	if "" != c.CronFile && "" == c.CronEndPoint {
 		log.Fatal("You need to specify SQSD_CRON_URL")
 	}
-	cronDaemon := cron_worker.New(&cron_worker.Config{
+	wConf.PeriodicTasksURL = c.CronEndPoint
+
+	cronLeaderElector := newCronLeaderElector(c, awsSess)
+
+	cronConfig := &cron_worker.Config{
 		File:                        c.CronFile,
 		EndPoint:                    c.CronEndPoint,
 		Timeout:                     time.Duration(c.CronTimeout) * time.Second,
+		Transport:                   backendTransport,
 		UserAgent:                   c.UserAgent,
 		HTTPContentType:             c.HTTPContentType,
 		HTTPAUTHORIZATIONHeader:     c.HTTPAUTHORIZATIONHeader,
+		HTTPAUTHORIZATIONHeaderFile: wConf.HTTPAUTHORIZATIONHeaderFile,
 		HTTPAUTHORIZATIONHeaderName: c.HTTPAUTHORIZATIONHeaderName,
-	})
+		TokenSource:                 wConf.TokenSource,
+		LeaderElector:               cronLeaderElector,
+		LeaderElectionInterval:      time.Duration(c.CronLeaderLease) * time.Second / 3,
+		StateStore:                  newCronStateStore(c, awsSess),
+		CatchupWindow:               time.Duration(c.CronCatchupWindow) * time.Second,
+	}
+	if c.CronEnqueue {
+		cronConfig.SQS = sqsSvc
+		cronConfig.QueueURL = c.QueueURL
+	}
+	cronDaemon := cron_worker.New(cronConfig)
 	if nil != cronDaemon {
 		go cronDaemon.Run()
 	}
 
+	if len(c.AdminAddress) > 0 {
+		go serveAdmin(c.AdminAddress, cronDaemon)
+	}
+
 	s := supervisor.NewSupervisor(logger, sqsSvc, httpClient, wConf)
 	s.Start(c.HTTPMaxConns)
 	s.Wait()
-	cronDaemon.Stop()
+	if nil != cronDaemon {
+		cronDaemon.Stop()
+	}
+}
+
+// newCronLeaderElector returns the elector deciding which replica runs cron entries, or nil when every
+// replica should run them.
+func newCronLeaderElector(c *config, awsSess *session.Session) cron_worker.LeaderElector {
+	if len(c.CronLeaderLockFile) > 0 {
+		return cron_worker.NewFileLock(c.CronLeaderLockFile)
+	}
+
+	if len(c.CronLeaderTable) > 0 {
+		hostname, _ := os.Hostname()
+		owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+
+		return cron_worker.NewDynamoDBLease(newDynamoDB(c, awsSess), c.CronLeaderTable, c.CronLeaderName, owner, time.Duration(c.CronLeaderLease)*time.Second)
+	}
+
+	return nil
+}
+
+// newCronStateStore returns the store recording when cron entries last ran, or nil when it is not configured.
+func newCronStateStore(c *config, awsSess *session.Session) cron_worker.StateStore {
+	if len(c.CronStateFile) > 0 {
+		return cron_worker.NewFileStateStore(c.CronStateFile)
+	}
+
+	if len(c.CronStateTable) > 0 {
+		return cron_worker.NewDynamoDBStateStore(newDynamoDB(c, awsSess), c.CronStateTable)
+	}
+
+	return nil
+}
+
+func newDynamoDB(c *config, awsSess *session.Session) *dynamodb.DynamoDB {
+	dynamoConfig := aws.NewConfig().WithRegion(c.QueueRegion)
+	if len(c.DynamoDBEndpoint) > 0 {
+		dynamoConfig.WithEndpoint(c.DynamoDBEndpoint)
+	}
+
+	return dynamodb.New(awsSess, dynamoConfig)
+}
+
+// watchSecretFile returns a reloadable secret for path, or nil when path is empty.
+func watchSecretFile(path string) *secret.File {
+	if len(path) == 0 {
+		return nil
+	}
+
+	f, err := secret.NewFile(path)
+	if err != nil {
+		log.Fatalf("Error while reading secret file %s: %s", path, err)
+	}
+
+	return f
+}
+
+// splitCommand splits a command into words the way a shell would, without expanding anything. Words are
+// separated by whitespace, single quotes preserve their contents as is, and backslashes escape the next
+// character outside of quotes, or one of " \ $ ` inside double quotes.
+func splitCommand(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("\"\\$`", command[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(command[i])
+			}
+			if i >= len(command) {
+				return nil, errors.New("unterminated double quote")
+			}
+		case c == '\\':
+			i++
+			if i >= len(command) {
+				return nil, errors.New("trailing backslash")
+			}
+			word.WriteByte(command[i])
+		default:
+			word.WriteByte(c)
+		}
+		inWord = true
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// unixSocketHost is the placeholder host of URLs rewritten by parseUnixSocketURL. Requests to it are
+// dialed through the configured socket.
+const unixSocketHost = "unix"
+
+// parseUnixSocketURL splits a unix:///path/to.sock:/request/path URL into the socket path and an http URL
+// for the request path on unixSocketHost.
+func parseUnixSocketURL(rawURL string) (string, string, bool) {
+	if !strings.HasPrefix(rawURL, "unix://") {
+		return "", rawURL, false
+	}
+
+	socketPath := strings.TrimPrefix(rawURL, "unix://")
+	path := "/"
+	if i := strings.Index(socketPath, ":"); i >= 0 {
+		socketPath, path = socketPath[:i], socketPath[i+1:]
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return socketPath, fmt.Sprintf("http://%s%s", unixSocketHost, path), true
+}
+
+// newBackendTransport returns the transport shared by the supervisor, cron worker and health check.
+func newBackendTransport(c *config, tlsConfig *tls.Config) *http.Transport {
+	transport := &http.Transport{
+		MaxIdleConns:        c.HTTPMaxConns,
+		MaxIdleConnsPerHost: c.HTTPMaxConns,
+		TLSClientConfig:     tlsConfig,
+	}
+
+	if len(c.HTTPSocket) > 0 {
+		dialer := &net.Dialer{}
+		transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			if addr == unixSocketHost+":80" {
+				return dialer.DialContext(ctx, "unix", c.HTTPSocket)
+			}
+
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+
+	return transport
+}
+
+// newTokenTransport returns the transport used to fetch OAuth 2.0 tokens. The token endpoint is usually a
+// separate identity provider, so it trusts the same CAs and TLS versions as the backend, but is dialed
+// normally and without the backend's server name or client certificate.
+func newTokenTransport(tlsConfig *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    tlsConfig.RootCAs,
+		MinVersion: tlsConfig.MinVersion,
+		MaxVersion: tlsConfig.MaxVersion,
+	}
+
+	return transport
+}
+
+// grpcCredentials returns the transport credentials used to connect to SQSD_GRPC_ADDRESS, which use the
+// same TLS configuration as HTTP requests unless plaintext was explicitly requested.
+func grpcCredentials(c *config, tlsConfig *tls.Config) credentials.TransportCredentials {
+	if c.GRPCInsecure {
+		return insecure.NewCredentials()
+	}
+
+	return credentials.NewTLS(tlsConfig)
+}
+
+func envelopeDecoders(c *config) []supervisor.EnvelopeDecoder {
+	decoders := make([]supervisor.EnvelopeDecoder, 0)
+
+	if c.UnwrapSNS {
+		decoders = append(decoders, supervisor.SNSEnvelopeDecoder{})
+	}
+
+	if c.UnwrapEventBridge {
+		decoders = append(decoders, supervisor.EventBridgeEnvelopeDecoder{})
+	}
+
+	if c.UnwrapS3Events {
+		decoders = append(decoders, supervisor.S3EventEnvelopeDecoder{})
+	}
+
+	return decoders
 }
 
 func getEnvInt(key string, def int) int {
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		words   []string
+		err     string
+	}{
+		{command: "", words: nil},
+		{command: "  php  artisan\tjob ", words: []string{"php", "artisan", "job"}},
+		{command: "sh -c 'php artisan job'", words: []string{"sh", "-c", "php artisan job"}},
+		{command: `sh -c "echo \"$X_AWS_SQSD_MSGID\" \\ \n"`, words: []string{"sh", "-c", `echo "$X_AWS_SQSD_MSGID" \ \n`}},
+		{command: `sh -c 'echo "it'\''s"'`, words: []string{"sh", "-c", `echo "it's"`}},
+		{command: `a\ b '' ""`, words: []string{"a b", "", ""}},
+		{command: "sh -c 'echo", err: "unterminated single quote"},
+		{command: `sh -c "echo`, err: "unterminated double quote"},
+		{command: `echo \`, err: "trailing backslash"},
+	}
+
+	for _, tt := range tests {
+		words, err := splitCommand(tt.command)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, tt.command)
+			continue
+		}
+
+		assert.NoError(t, err, tt.command)
+		assert.Equal(t, tt.words, words, tt.command)
+	}
+}
+
+func TestParseUnixSocketURL(t *testing.T) {
+	tests := []struct {
+		rawURL     string
+		socketPath string
+		httpURL    string
+		ok         bool
+	}{
+		{rawURL: "http://localhost:8080/jobs", httpURL: "http://localhost:8080/jobs"},
+		{rawURL: "unix:///var/run/app.sock", socketPath: "/var/run/app.sock", httpURL: "http://unix/", ok: true},
+		{rawURL: "unix:///var/run/app.sock:/jobs", socketPath: "/var/run/app.sock", httpURL: "http://unix/jobs", ok: true},
+		{rawURL: "unix:///var/run/app.sock:jobs", socketPath: "/var/run/app.sock", httpURL: "http://unix/jobs", ok: true},
+		{rawURL: "unix:///var/run/app.sock:/jobs?queue=default", socketPath: "/var/run/app.sock", httpURL: "http://unix/jobs?queue=default", ok: true},
+		{rawURL: "unix://app.sock:/", socketPath: "app.sock", httpURL: "http://unix/", ok: true},
+	}
+
+	for _, tt := range tests {
+		socketPath, httpURL, ok := parseUnixSocketURL(tt.rawURL)
+
+		assert.Equal(t, tt.socketPath, socketPath, tt.rawURL)
+		assert.Equal(t, tt.httpURL, httpURL, tt.rawURL)
+		assert.Equal(t, tt.ok, ok, tt.rawURL)
+	}
+}
+
+func TestBackendTransportUnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "app.sock")
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	unixServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("unix " + r.URL.RequestURI()))
+	}))
+	unixServer.Listener.Close()
+	unixServer.Listener = l
+	unixServer.Start()
+	defer unixServer.Close()
+
+	tcpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tcp " + r.URL.RequestURI()))
+	}))
+	defer tcpServer.Close()
+
+	c := &config{HTTPMaxConns: 1}
+	var ok bool
+	c.HTTPSocket, c.HTTPURL, ok = parseUnixSocketURL("unix://" + socketPath + ":/jobs?queue=default")
+	require.True(t, ok)
+
+	client := &http.Client{Transport: newBackendTransport(c, nil)}
+
+	get := func(url string) string {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	assert.Equal(t, "unix /jobs?queue=default", get(c.HTTPURL))
+	// only the placeholder host is dialed through the socket
+	assert.Equal(t, "tcp /health", get(tcpServer.URL+"/health"))
+}
+
+func TestTokenTransport(t *testing.T) {
+	idp := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.TLS.PeerCertificates)
+		w.Write([]byte("token"))
+	}))
+	defer idp.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(idp.Certificate())
+
+	backendTLSConfig := &tls.Config{
+		RootCAs:    pool,
+		ServerName: "backend.internal",
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			t.Error("the backend client certificate was requested")
+			return &tls.Certificate{}, nil
+		},
+	}
+
+	transport := newTokenTransport(backendTLSConfig)
+	assert.Empty(t, transport.TLSClientConfig.ServerName)
+	assert.Nil(t, transport.TLSClientConfig.GetClientCertificate)
+	assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+
+	// the backend's server name override would fail verification against the identity provider
+	_, err := (&http.Client{Transport: newBackendTransport(&config{}, backendTLSConfig)}).Get(idp.URL)
+	assert.Error(t, err)
+
+	resp, err := (&http.Client{Transport: transport}).Get(idp.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "token", string(body))
+}
+
+func TestGRPCCredentials(t *testing.T) {
+	tlsConfig := &tls.Config{ServerName: "worker.internal"}
+
+	creds := grpcCredentials(&config{}, tlsConfig)
+	assert.Equal(t, "tls", creds.Info().SecurityProtocol)
+	assert.Equal(t, "worker.internal", creds.Info().ServerName)
+
+	creds = grpcCredentials(&config{GRPCInsecure: true}, tlsConfig)
+	assert.Equal(t, "insecure", creds.Info().SecurityProtocol)
+}
+
+// writeClientCert writes a self-signed client certificate and its key to dir, returning their paths and
+// the parsed certificate.
+func writeClientCert(t *testing.T, dir string, name string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return certPath, keyPath, cert
+}
+
+func TestNewTLSConfigVersions(t *testing.T) {
+	tests := []struct {
+		min, max               string
+		minVersion, maxVersion uint16
+		err                    string
+	}{
+		{minVersion: tls.VersionTLS12},
+		{min: "1.0", max: "1.1", minVersion: tls.VersionTLS10, maxVersion: tls.VersionTLS11},
+		{min: "1.3", minVersion: tls.VersionTLS13},
+		{max: "1.3", minVersion: tls.VersionTLS12, maxVersion: tls.VersionTLS13},
+		{min: "1.2", max: "1.2", minVersion: tls.VersionTLS12, maxVersion: tls.VersionTLS12},
+		{min: "1.4", err: "unknown TLS version: 1.4"},
+		{max: "TLSv1.3", err: "unknown TLS version: TLSv1.3"},
+		{min: "1.3", max: "1.2", err: "minimum TLS version 1.3 is greater than maximum TLS version 1.2"},
+		{max: "1.1", err: "minimum TLS version 1.2 is greater than maximum TLS version 1.1"},
+	}
+
+	for _, tt := range tests {
+		tlsConfig, err := newTLSConfig(&config{SSLVerify: true, TLSMinVersion: tt.min, TLSMaxVersion: tt.max})
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, "%s-%s", tt.min, tt.max)
+			continue
+		}
+
+		require.NoError(t, err, "%s-%s", tt.min, tt.max)
+		assert.Equal(t, tt.minVersion, tlsConfig.MinVersion, "%s-%s", tt.min, tt.max)
+		assert.Equal(t, tt.maxVersion, tlsConfig.MaxVersion, "%s-%s", tt.min, tt.max)
+	}
+}
+
+func TestNewTLSConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, _ := writeClientCert(t, dir, "client")
+
+	tlsConfig, err := newTLSConfig(&config{SSLVerify: true})
+	require.NoError(t, err)
+	assert.Nil(t, tlsConfig.RootCAs)
+	assert.Nil(t, tlsConfig.GetClientCertificate)
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+
+	tlsConfig, err = newTLSConfig(&config{TLSServerName: "backend.internal"})
+	require.NoError(t, err)
+	assert.Equal(t, "backend.internal", tlsConfig.ServerName)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+
+	// the CA bundle is trusted in addition to the system roots
+	tlsConfig, err = newTLSConfig(&config{SSLVerify: true, TLSCAFile: certPath})
+	require.NoError(t, err)
+	systemPool, err := x509.SystemCertPool()
+	if err != nil {
+		systemPool = x509.NewCertPool()
+	}
+	assert.Len(t, tlsConfig.RootCAs.Subjects(), len(systemPool.Subjects())+1)
+
+	_, err = newTLSConfig(&config{TLSCAFile: filepath.Join(dir, "missing.crt")})
+	assert.Error(t, err)
+
+	_, err = newTLSConfig(&config{TLSCAFile: keyPath})
+	assert.EqualError(t, err, "no certificates found in CA bundle "+keyPath)
+
+	_, err = newTLSConfig(&config{TLSCertFile: certPath})
+	assert.Error(t, err)
+
+	_, err = newTLSConfig(&config{TLSCertFile: keyPath, TLSKeyFile: certPath})
+	assert.Error(t, err)
+}
+
+func TestNewTLSConfigMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCert := writeClientCert(t, dir, "client")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	caPath := filepath.Join(dir, "server-ca.crt")
+	require.NoError(t, ioutil.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	get := func(c *config) (string, error) {
+		c.SSLVerify = true
+		c.HTTPMaxConns = 1
+		tlsConfig, err := newTLSConfig(c)
+		require.NoError(t, err)
+
+		resp, err := (&http.Client{Transport: newBackendTransport(c, tlsConfig)}).Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	body, err := get(&config{TLSCAFile: caPath, TLSCertFile: certPath, TLSKeyFile: keyPath})
+	require.NoError(t, err)
+	assert.Equal(t, "client", body)
+
+	// the httptest certificate is issued for example.com
+	body, err = get(&config{TLSCAFile: caPath, TLSCertFile: certPath, TLSKeyFile: keyPath, TLSServerName: "example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "client", body)
+
+	_, err = get(&config{TLSCAFile: caPath, TLSCertFile: certPath, TLSKeyFile: keyPath, TLSServerName: "backend.internal"})
+	assert.Error(t, err)
+
+	_, err = get(&config{TLSCAFile: caPath})
+	assert.Error(t, err)
+
+	_, err = get(&config{TLSCertFile: certPath, TLSKeyFile: keyPath})
+	assert.Error(t, err)
+}
+
+func TestClientCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, oldCert := writeClientCert(t, dir, "old")
+	newCertPath, newKeyPath, newCert := writeClientCert(t, dir, "new")
+
+	r, err := newClientCertReloader(certPath, keyPath)
+	require.NoError(t, err)
+	defer r.certFile.Close()
+	defer r.keyFile.Close()
+
+	current := func() []byte {
+		cert, err := r.getClientCertificate(nil)
+		require.NoError(t, err)
+		return cert.Certificate[0]
+	}
+
+	assert.Equal(t, oldCert.Raw, current())
+
+	// the new certificate does not match the old key, so the old pair is kept
+	newCertPEM, err := ioutil.ReadFile(newCertPath)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(certPath, newCertPEM, 0600))
+	assert.Eventually(t, func() bool {
+		return r.certFile.String() == strings.TrimRight(string(newCertPEM), "\n")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, oldCert.Raw, current())
+
+	newKeyPEM, err := ioutil.ReadFile(newKeyPath)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(keyPath, newKeyPEM, 0600))
+	assert.Eventually(t, func() bool {
+		return string(newCert.Raw) == string(current())
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestValidateCron(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cron.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`version: 1
+cron:
+  - name: "report"
+    schedule: "0 * * * *"
+`), 0644))
+
+	var stdout, stderr strings.Builder
+	assert.Equal(t, 1, validateCron([]string{path}, &stdout, &stderr))
+	assert.Equal(t, path+`:3: entry "report": url is required`+"\n", stderr.String())
+	assert.Empty(t, stdout.String())
+
+	stdout.Reset()
+	stderr.Reset()
+	assert.Equal(t, 2, validateCron(nil, &stdout, &stderr))
+}
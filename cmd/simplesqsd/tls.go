@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/fterrag/simple-sqsd/secret"
+	log "github.com/sirupsen/logrus"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// defaultTLSMinVersion is the minimum TLS version used unless SQSD_HTTP_TLS_MIN_VERSION is set.
+const defaultTLSMinVersion = "1.2"
+
+// newTLSConfig returns the TLS configuration used for requests made to the backend.
+func newTLSConfig(c *config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: !c.SSLVerify,
+		ServerName:         c.TLSServerName,
+	}
+
+	minVersion := c.TLSMinVersion
+	if len(minVersion) == 0 {
+		minVersion = defaultTLSMinVersion
+	}
+
+	v, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unknown TLS version: %s", minVersion)
+	}
+	tlsConfig.MinVersion = v
+
+	if len(c.TLSMaxVersion) > 0 {
+		v, ok := tlsVersions[c.TLSMaxVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS version: %s", c.TLSMaxVersion)
+		}
+		tlsConfig.MaxVersion = v
+	}
+
+	if tlsConfig.MaxVersion != 0 && tlsConfig.MinVersion > tlsConfig.MaxVersion {
+		return nil, fmt.Errorf("minimum TLS version %s is greater than maximum TLS version %s", minVersion, c.TLSMaxVersion)
+	}
+
+	if len(c.TLSCAFile) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := ioutil.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %s", err)
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", c.TLSCAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(c.TLSCertFile) > 0 || len(c.TLSKeyFile) > 0 {
+		certs, err := newClientCertReloader(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.GetClientCertificate = certs.getClientCertificate
+	}
+
+	return tlsConfig, nil
+}
+
+// clientCertReloader holds a client certificate that is reloaded whenever its certificate or key file changes.
+type clientCertReloader struct {
+	certFile *secret.File
+	keyFile  *secret.File
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newClientCertReloader(certPath string, keyPath string) (*clientCertReloader, error) {
+	certFile, err := secret.NewFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading client certificate: %s", err)
+	}
+
+	keyFile, err := secret.NewFile(keyPath)
+	if err != nil {
+		certFile.Close()
+		return nil, fmt.Errorf("error loading client certificate key: %s", err)
+	}
+
+	r := &clientCertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	certFile.OnChange(r.onChange)
+	keyFile.OnChange(r.onChange)
+
+	return r, nil
+}
+
+func (r *clientCertReloader) reload() error {
+	cert, err := tls.X509KeyPair(r.certFile.Bytes(), r.keyFile.Bytes())
+	if err != nil {
+		return fmt.Errorf("error loading client certificate: %s", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+
+	return nil
+}
+
+// onChange keeps using the previous certificate until both files hold a matching pair, as the
+// certificate and key are usually not written at the exact same time.
+func (r *clientCertReloader) onChange() {
+	if err := r.reload(); err != nil {
+		log.WithError(err).Warn("Client certificate changed but could not be loaded, keeping the previous one")
+		return
+	}
+
+	log.Info("Client certificate reloaded")
+}
+
+func (r *clientCertReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/fterrag/simple-sqsd/cron_worker"
+	log "github.com/sirupsen/logrus"
+)
+
+// validateCron implements the validate-cron subcommand, which checks a cron.yaml file the way it is loaded
+// and prints when each entry will next fire. It returns the exit code.
+func validateCron(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate-cron", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	count := flags.Int("n", 5, "number of upcoming fire times to print for each entry")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: simplesqsd validate-cron [-n count] <cron.yaml>")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	// problems are reported below rather than logged while loading
+	log.SetLevel(log.WarnLevel)
+
+	path := flags.Arg(0)
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1
+	}
+
+	previews, problems := cron_worker.ValidateCronTab(contents, *count, time.Now())
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			location := path
+			if problem.Line > 0 {
+				location = fmt.Sprintf("%s:%d", path, problem.Line)
+			}
+			fmt.Fprintf(stderr, "%s: %s\n", location, problem.Message())
+		}
+		return 1
+	}
+
+	for _, preview := range previews {
+		timezone := preview.Timezone
+		if len(timezone) == 0 {
+			timezone = "Local"
+		}
+		fmt.Fprintf(stdout, "%s\t%s\t(%s)\n", preview.Name, preview.Schedule, timezone)
+		for _, next := range preview.Next {
+			fmt.Fprintf(stdout, "\t%s\n", next.Format(time.RFC3339))
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,242 @@
+package cron_worker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// historySize is how many runs of each entry are kept
+const historySize = 20
+
+// ErrUnknownEntry is returned for names not matching a scheduled entry
+var ErrUnknownEntry = errors.New("unknown cron entry")
+
+type (
+	// RunResult is the outcome of a run of an entry
+	RunResult struct {
+		Start      time.Time `json:"start"`
+		Duration   string    `json:"duration"`
+		StatusCode int       `json:"status_code,omitempty"`
+		Error      string    `json:"error,omitempty"`
+	}
+
+	// EntryStatus describes an entry and its recent runs
+	EntryStatus struct {
+		Name     string      `json:"name"`
+		Url      string      `json:"url"`
+		Schedule string      `json:"schedule"`
+		Timezone string      `json:"timezone,omitempty"`
+		Paused   bool        `json:"paused"`
+		Next     *time.Time  `json:"next,omitempty"`
+		Prev     *time.Time  `json:"prev,omitempty"`
+		LastRun  *RunResult  `json:"last_run,omitempty"`
+		History  []RunResult `json:"history,omitempty"`
+	}
+)
+
+func (w *Worker) recordResult(name string, result RunResult) {
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+
+	if nil == w.history {
+		w.history = make(map[string][]RunResult)
+	}
+
+	history := append(w.history[name], result)
+	if len(history) > historySize {
+		history = history[len(history)-historySize:]
+	}
+	w.history[name] = history
+}
+
+func (w *Worker) isPaused(name string) bool {
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+
+	return w.paused[name]
+}
+
+func (w *Worker) setPaused(name string, paused bool) error {
+	if _, ok := w.scheduledEntry(name); !ok {
+		return ErrUnknownEntry
+	}
+
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+
+	if nil == w.paused {
+		w.paused = make(map[string]bool)
+	}
+	w.paused[name] = paused
+
+	log.WithField("what", "cron").WithField("entry", name).WithField("paused", paused).Info("Cron entry paused state changed")
+	return nil
+}
+
+// Pause stops the entry from running on its schedule until it is resumed
+func (w *Worker) Pause(name string) error {
+	return w.setPaused(name, true)
+}
+
+// Resume lets a paused entry run on its schedule again
+func (w *Worker) Resume(name string) error {
+	return w.setPaused(name, false)
+}
+
+// Trigger runs the entry straight away in the background, even when it is paused. The run still follows the
+// entry's overlap policy, so it is skipped or queued while a previous run is in progress
+func (w *Worker) Trigger(name string) error {
+	entry, ok := w.scheduledEntry(name)
+	if !ok {
+		return ErrUnknownEntry
+	}
+
+	log.WithField("what", "cron").WithField("entry", name).Info("Cron entry triggered")
+	go entry.guard(func() {
+		w.runCronEntry(entry)
+	})
+	return nil
+}
+
+func (w *Worker) scheduledEntry(name string) (sqsCronItem, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if nil != w.crontab {
+		for _, entry := range w.crontab.Cron {
+			if name == entry.Name && 0 != entry.cronEntryId {
+				return entry, true
+			}
+		}
+	}
+	return sqsCronItem{}, false
+}
+
+// Entries returns the status of every scheduled entry
+func (w *Worker) Entries() []EntryStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	statuses := make([]EntryStatus, 0)
+	if nil != w.crontab {
+		for _, entry := range w.crontab.Cron {
+			if 0 != entry.cronEntryId {
+				statuses = append(statuses, w.entryStatus(entry, false))
+			}
+		}
+	}
+	return statuses
+}
+
+// Entry returns the status of the named entry, including its run history
+func (w *Worker) Entry(name string) (EntryStatus, error) {
+	entry, ok := w.scheduledEntry(name)
+	if !ok {
+		return EntryStatus{}, ErrUnknownEntry
+	}
+	return w.entryStatus(entry, true), nil
+}
+
+func (w *Worker) entryStatus(entry sqsCronItem, withHistory bool) EntryStatus {
+	status := EntryStatus{
+		Name:     entry.Name,
+		Url:      entry.Url,
+		Schedule: entry.Schedule,
+		Timezone: entry.Timezone,
+	}
+
+	cronEntry := w.cron.Entry(entry.cronEntryId)
+	if !cronEntry.Next.IsZero() {
+		status.Next = &cronEntry.Next
+	}
+	if !cronEntry.Prev.IsZero() {
+		status.Prev = &cronEntry.Prev
+	}
+
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+
+	status.Paused = w.paused[entry.Name]
+	if history := w.history[entry.Name]; len(history) > 0 {
+		last := history[len(history)-1]
+		status.LastRun = &last
+		if withHistory {
+			status.History = append([]RunResult(nil), history...)
+		}
+	}
+	return status
+}
+
+// Handler serves the admin API for cron entries:
+//
+//	GET  /cron/entries                  lists entries
+//	GET  /cron/entries/{name}           shows an entry and its run history
+//	POST /cron/entries/{name}/trigger   runs an entry straight away
+//	POST /cron/entries/{name}/pause     pauses an entry
+//	POST /cron/entries/{name}/resume    resumes a paused entry
+func (w *Worker) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/cron/entries")
+		if "" == path || "/" == path {
+			if http.MethodGet != r.Method {
+				http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			writeJSON(rw, http.StatusOK, w.Entries())
+			return
+		}
+
+		name := strings.TrimPrefix(path, "/")
+		if http.MethodGet == r.Method {
+			status, err := w.Entry(name)
+			if nil != err {
+				http.Error(rw, err.Error(), http.StatusNotFound)
+				return
+			}
+			writeJSON(rw, http.StatusOK, status)
+			return
+		}
+
+		if http.MethodPost != r.Method {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		idx := strings.LastIndex(name, "/")
+		if idx < 0 {
+			http.NotFound(rw, r)
+			return
+		}
+
+		var err error
+		switch name[idx+1:] {
+		case "trigger":
+			err = w.Trigger(name[:idx])
+		case "pause":
+			err = w.Pause(name[:idx])
+		case "resume":
+			err = w.Resume(name[:idx])
+		default:
+			http.NotFound(rw, r)
+			return
+		}
+		if nil != err {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		rw.WriteHeader(http.StatusAccepted)
+	})
+}
+
+func writeJSON(rw http.ResponseWriter, statusCode int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(statusCode)
+	if err := json.NewEncoder(rw).Encode(v); nil != err {
+		log.WithError(err).Error("Failed to write admin response")
+	}
+}
@@ -0,0 +1,115 @@
+package cron_worker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminHandler(t *testing.T) {
+	requests := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	w := &Worker{config: &Config{EndPoint: ts.URL, Timeout: time.Second}, cron: cron.New(cron.WithParser(cronParser))}
+	crontab := &sqsCron{Version: 1, Cron: []sqsCronItem{{Name: "report", Url: "/report", Schedule: "0 * * * *"}}}
+	require.NoError(t, w.loadCronEntries(crontab))
+	w.crontab = crontab
+
+	admin := httptest.NewServer(w.Handler())
+	defer admin.Close()
+
+	res, err := http.Post(admin.URL+"/cron/entries/report/trigger", "", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	assert.Equal(t, "/report", <-requests)
+
+	assert.Eventually(t, func() bool {
+		status, err := w.Entry("report")
+		return nil == err && nil != status.LastRun
+	}, 5*time.Second, 10*time.Millisecond)
+
+	res, err = http.Post(admin.URL+"/cron/entries/report/pause", "", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+	// paused entries do not run on their schedule
+	w.makeCronRequestFunc(crontab.Cron[0])()
+	select {
+	case <-requests:
+		assert.Fail(t, "paused entry ran")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	res, err = http.Get(admin.URL + "/cron/entries")
+	require.NoError(t, err)
+	var statuses []EntryStatus
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&statuses))
+	res.Body.Close()
+
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "report", statuses[0].Name)
+	assert.True(t, statuses[0].Paused)
+	assert.Equal(t, http.StatusNoContent, statuses[0].LastRun.StatusCode)
+
+	res, err = http.Get(admin.URL + "/cron/entries/report")
+	require.NoError(t, err)
+	var status EntryStatus
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&status))
+	res.Body.Close()
+	assert.Len(t, status.History, 1)
+
+	res, err = http.Post(admin.URL+"/cron/entries/unknown/trigger", "", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestTriggerOverlapSkip(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer ts.Close()
+
+	w := &Worker{config: &Config{EndPoint: ts.URL, Timeout: 5 * time.Second}, cron: cron.New(cron.WithParser(cronParser))}
+	crontab := &sqsCron{Version: 1, Cron: []sqsCronItem{{Name: "trigger-skip", Url: "/report", Schedule: "0 * * * *", Overlap: OverlapSkip}}}
+	require.NoError(t, w.loadCronEntries(crontab))
+	w.crontab = crontab
+
+	// a scheduled run is in progress
+	scheduled := make(chan struct{})
+	go func() {
+		w.cron.Entry(crontab.Cron[0].cronEntryId).Job.Run()
+		close(scheduled)
+	}()
+	<-started
+
+	require.NoError(t, w.Trigger("trigger-skip"))
+	assert.Eventually(t, func() bool {
+		skipped := skippedRuns.Get("trigger-skip")
+		return nil != skipped && "1" == skipped.String()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(release)
+	<-scheduled
+
+	select {
+	case <-started:
+		assert.Fail(t, "triggered run overlapped the scheduled one")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,47 @@
+package cron_worker
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/fterrag/simple-sqsd/supervisor"
+	log "github.com/sirupsen/logrus"
+)
+
+// enqueueCronTask sends a periodic task message for the entry to the queue, the way Elastic Beanstalk does,
+// so it is delivered by the supervisor with the queue's retries and visibility timeout
+func (w *Worker) enqueueCronTask(entry sqsCronItem) error {
+	scheduledAt := time.Now().UTC()
+	rqLog := log.
+		WithField("what", "cron").
+		WithField("entry", entry.Name).
+		WithField("queue", w.config.QueueURL).
+		WithField("scheduled-at", scheduledAt)
+
+	output, err := w.config.SQS.SendMessage(&sqs.SendMessageInput{
+		QueueUrl:    aws.String(w.config.QueueURL),
+		MessageBody: aws.String(supervisor.PeriodicTaskBody),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			supervisor.PeriodicTaskNameAttribute: {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(entry.Name),
+			},
+			supervisor.PeriodicTaskPathAttribute: {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(entry.Url),
+			},
+			supervisor.PeriodicTaskScheduledAtAttribute: {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(scheduledAt.Format(time.RFC3339)),
+			},
+		},
+	})
+	if nil != err {
+		rqLog.WithError(err).Error("Failed Enqueueing Cron Task")
+		return err
+	}
+
+	rqLog.WithField("message-id", aws.StringValue(output.MessageId)).Info("Cron Task Enqueued")
+	return nil
+}
@@ -0,0 +1,76 @@
+package cron_worker
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/fterrag/simple-sqsd/supervisor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSQS struct {
+	sqsiface.SQSAPI
+
+	sendMessageFunc func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error)
+}
+
+func (m *mockSQS) SendMessage(input *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	return m.sendMessageFunc(input)
+}
+
+func TestWorkerEnqueueCronTask(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("the cron endpoint was requested: %s", r.URL.Path)
+	}))
+	defer ts.Close()
+
+	var inputs []*sqs.SendMessageInput
+	mock := &mockSQS{}
+	mock.sendMessageFunc = func(input *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		inputs = append(inputs, input)
+		return &sqs.SendMessageOutput{MessageId: aws.String("m1")}, nil
+	}
+
+	w := &Worker{config: &Config{
+		EndPoint: ts.URL,
+		SQS:      mock,
+		QueueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/worker",
+	}}
+
+	before := time.Now().UTC().Truncate(time.Second)
+	w.makeCronRequestFunc(sqsCronItem{Name: "report", Url: "/tasks/report", Schedule: "0 * * * *"})()
+
+	require.Len(t, inputs, 1)
+	input := inputs[0]
+	assert.Equal(t, "https://sqs.us-east-1.amazonaws.com/123456789012/worker", aws.StringValue(input.QueueUrl))
+	assert.Equal(t, supervisor.PeriodicTaskBody, aws.StringValue(input.MessageBody))
+	assert.Len(t, input.MessageAttributes, 3)
+
+	assert.Equal(t, "report", aws.StringValue(input.MessageAttributes[supervisor.PeriodicTaskNameAttribute].StringValue))
+	assert.Equal(t, "/tasks/report", aws.StringValue(input.MessageAttributes[supervisor.PeriodicTaskPathAttribute].StringValue))
+
+	scheduledAt := input.MessageAttributes[supervisor.PeriodicTaskScheduledAtAttribute]
+	require.NotNil(t, scheduledAt)
+	assert.Equal(t, "String", aws.StringValue(scheduledAt.DataType))
+	parsed, err := time.Parse(time.RFC3339, aws.StringValue(scheduledAt.StringValue))
+	require.NoError(t, err)
+	assert.False(t, parsed.Before(before))
+	assert.False(t, parsed.After(time.Now().UTC()))
+
+	// failures to enqueue are recorded like failed requests
+	mock.sendMessageFunc = func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		return nil, errors.New("queue unavailable")
+	}
+	w.makeCronRequestFunc(sqsCronItem{Name: "report", Url: "/tasks/report", Schedule: "0 * * * *"})()
+
+	require.Len(t, w.history["report"], 2)
+	assert.Empty(t, w.history["report"][0].Error)
+	assert.Equal(t, "queue unavailable", w.history["report"][1].Error)
+}
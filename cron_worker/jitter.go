@@ -0,0 +1,65 @@
+package cron_worker
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// jitterRand is seeded per process, unlike the default source on older Go versions, so that replicas
+	// pick different jitter
+	jitterRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	jitterRandMu sync.Mutex
+)
+
+// randomJitter returns a random delay within jitter
+func randomJitter(jitter time.Duration) time.Duration {
+	jitterRandMu.Lock()
+	defer jitterRandMu.Unlock()
+
+	return time.Duration(jitterRand.Int63n(int64(jitter)))
+}
+
+// spreadOffset returns a delay within spread derived from the entry's name, so that it is the same on every
+// replica and across restarts
+func spreadOffset(name string, spread time.Duration) time.Duration {
+	if spread <= 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return time.Duration(h.Sum64() % uint64(spread))
+}
+
+// delayWrapper delays each run of the entry by its spread offset plus a random jitter
+func delayWrapper(entry sqsCronItem) cron.JobWrapper {
+	offset := spreadOffset(entry.Name, entry.Spread)
+
+	return func(j cron.Job) cron.Job {
+		if 0 == offset && entry.Jitter <= 0 {
+			return j
+		}
+
+		return cron.FuncJob(func() {
+			delay := offset
+			if entry.Jitter > 0 {
+				delay += randomJitter(entry.Jitter)
+			}
+
+			log.
+				WithField("what", "cron").
+				WithField("entry", entry.Name).
+				WithField("delay", delay.String()).
+				Debug("Delaying cron run")
+			time.Sleep(delay)
+
+			j.Run()
+		})
+	}
+}
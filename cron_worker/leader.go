@@ -0,0 +1,124 @@
+package cron_worker
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// LeaderElector decides which replica runs cron entries when several load the same cron.yaml
+	LeaderElector interface {
+		// Acquire takes or renews leadership, returning whether this replica is the leader
+		Acquire() (bool, error)
+		// Release gives up leadership so another replica can take over straight away
+		Release() error
+	}
+
+	// FileLock elects the process holding an exclusive lock on a file, for replicas sharing a host.
+	// The lock is released by the kernel if the process dies, letting another replica take over.
+	FileLock struct {
+		path string
+
+		mu sync.Mutex
+		fh *os.File
+	}
+)
+
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+func (l *FileLock) Acquire() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if nil != l.fh {
+		return true, nil
+	}
+
+	fh, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if nil != err {
+		return false, err
+	}
+
+	if err = syscall.Flock(int(fh.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); nil != err {
+		fh.Close()
+		if syscall.EWOULDBLOCK == err {
+			return false, nil
+		}
+		return false, err
+	}
+
+	l.fh = fh
+	return true, nil
+}
+
+func (l *FileLock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if nil == l.fh {
+		return nil
+	}
+
+	err := l.fh.Close()
+	l.fh = nil
+	return err
+}
+
+// isLeader reports whether this replica should run cron entries. Without an elector every replica is the leader
+func (w *Worker) isLeader() bool {
+	if nil == w.config.LeaderElector {
+		return true
+	}
+
+	w.leaderMu.Lock()
+	defer w.leaderMu.Unlock()
+	return w.leader
+}
+
+// elect periodically campaigns for leadership until Stop is called, releasing it on the way out
+func (w *Worker) elect() {
+	ticker := time.NewTicker(w.config.LeaderElectionInterval)
+	defer ticker.Stop()
+
+	for {
+		w.campaign()
+
+		select {
+		case <-w.electDoneChan:
+			if err := w.config.LeaderElector.Release(); nil != err {
+				log.WithField("what", "cron").WithError(err).Error("Failed to release cron leadership")
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) campaign() {
+	leader, err := w.config.LeaderElector.Acquire()
+	if nil != err {
+		// without a renewed lease another replica may take over, so stop running entries to be safe
+		log.WithField("what", "cron").WithError(err).Error("Failed to acquire cron leadership")
+		leader = false
+	}
+
+	w.leaderMu.Lock()
+	elected := leader && !w.leader
+	if leader != w.leader {
+		log.WithField("what", "cron").WithField("leader", leader).Info("Cron leadership changed")
+	}
+	w.leader = leader
+	w.leaderMu.Unlock()
+
+	// runs due while no replica was the leader, such as while the previous one restarted, are made up by
+	// the new leader
+	if elected {
+		w.catchUp(time.Now())
+	}
+}
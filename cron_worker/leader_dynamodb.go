@@ -0,0 +1,87 @@
+package cron_worker
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// DynamoDBLease elects the replica holding a lease stored in a DynamoDB table, keyed by a LockName string
+// partition key. The lease is taken and renewed with conditional writes, and expires if the leader stops
+// renewing it, letting another replica take over.
+type DynamoDBLease struct {
+	db       dynamodbiface.DynamoDBAPI
+	table    string
+	name     string
+	owner    string
+	duration time.Duration
+}
+
+func NewDynamoDBLease(db dynamodbiface.DynamoDBAPI, table string, name string, owner string, duration time.Duration) *DynamoDBLease {
+	return &DynamoDBLease{
+		db:       db,
+		table:    table,
+		name:     name,
+		owner:    owner,
+		duration: duration,
+	}
+}
+
+func (l *DynamoDBLease) Acquire() (bool, error) {
+	now := time.Now()
+
+	_, err := l.db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(l.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"LockName": {S: aws.String(l.name)},
+			"Owner":    {S: aws.String(l.owner)},
+			"Expires":  {N: aws.String(strconv.FormatInt(now.Add(l.duration).UnixNano()/int64(time.Millisecond), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#name) OR #owner = :owner OR #expires < :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#name":    aws.String("LockName"),
+			"#owner":   aws.String("Owner"),
+			"#expires": aws.String("Expires"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(l.owner)},
+			":now":   {N: aws.String(strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10))},
+		},
+	})
+	if nil != err {
+		if aerr, ok := err.(awserr.Error); ok && dynamodb.ErrCodeConditionalCheckFailedException == aerr.Code() {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (l *DynamoDBLease) Release() error {
+	_, err := l.db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(l.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"LockName": {S: aws.String(l.name)},
+		},
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("Owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(l.owner)},
+		},
+	})
+	if nil != err {
+		if aerr, ok := err.(awserr.Error); ok && dynamodb.ErrCodeConditionalCheckFailedException == aerr.Code() {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
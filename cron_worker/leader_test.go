@@ -0,0 +1,183 @@
+package cron_worker
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+
+	putItemFunc func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+}
+
+func (m *mockDynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	return m.putItemFunc(input)
+}
+
+type mockLeaderElector struct {
+	leader bool
+}
+
+func (m *mockLeaderElector) Acquire() (bool, error) {
+	return m.leader, nil
+}
+
+func (m *mockLeaderElector) Release() error {
+	return nil
+}
+
+func TestWorkerCatchUpOnElection(t *testing.T) {
+	requests := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.URL.Path
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cron.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`version: 1
+cron:
+  - name: "missed"
+    url: "/missed"
+    schedule: "@every 90m"
+    catchup: once
+`), 0644))
+
+	// the previous leader last ran the entry before going away, and its next run fell between leaders
+	store := NewFileStateStore(filepath.Join(dir, "state.json"))
+	require.NoError(t, store.SetLastRun("missed", time.Now().Add(-100*time.Minute)))
+
+	elector := &mockLeaderElector{}
+	w := New(&Config{File: path, EndPoint: ts.URL, StateStore: store, LeaderElector: elector})
+
+	w.campaign()
+	select {
+	case path := <-requests:
+		assert.Fail(t, "caught up without being the leader", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	elector.leader = true
+	w.campaign()
+	select {
+	case path := <-requests:
+		assert.Equal(t, "/missed", path)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "missed run was not caught up by the new leader")
+	}
+
+	// staying the leader does not catch up again
+	w.campaign()
+	select {
+	case path := <-requests:
+		assert.Fail(t, "unexpected catch-up run", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cron.lock")
+
+	a := NewFileLock(path)
+	b := NewFileLock(path)
+
+	leader, err := a.Acquire()
+	assert.NoError(t, err)
+	assert.True(t, leader)
+
+	leader, err = b.Acquire()
+	assert.NoError(t, err)
+	assert.False(t, leader)
+
+	// renewing keeps leadership
+	leader, err = a.Acquire()
+	assert.NoError(t, err)
+	assert.True(t, leader)
+
+	assert.NoError(t, a.Release())
+
+	leader, err = b.Acquire()
+	assert.NoError(t, err)
+	assert.True(t, leader)
+}
+
+func TestDynamoDBLeaseConditionalCheckFailed(t *testing.T) {
+	db := &mockDynamoDB{}
+	db.putItemFunc = func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+		assert.Equal(t, "locks", *input.TableName)
+		assert.Equal(t, "cron", *input.Item["LockName"].S)
+		assert.Equal(t, "a", *input.Item["Owner"].S)
+
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+
+	leader, err := NewDynamoDBLease(db, "locks", "cron", "a", time.Minute).Acquire()
+	assert.NoError(t, err)
+	assert.False(t, leader)
+}
+
+// TestDynamoDBLease runs against DynamoDB Local (or another stand-in) when SQSD_TEST_DYNAMODB_ENDPOINT is set.
+func TestDynamoDBLease(t *testing.T) {
+	endpoint := os.Getenv("SQSD_TEST_DYNAMODB_ENDPOINT")
+	if len(endpoint) == 0 {
+		t.Skip("SQSD_TEST_DYNAMODB_ENDPOINT is not set")
+	}
+
+	db := dynamodb.New(session.Must(session.NewSession()), aws.NewConfig().
+		WithRegion("us-east-1").
+		WithEndpoint(endpoint).
+		WithCredentials(credentials.NewStaticCredentials("test", "test", "")))
+
+	table := "simple-sqsd-test-" + time.Now().Format("20060102150405.000000")
+	_, err := db.CreateTable(&dynamodb.CreateTableInput{
+		TableName:            aws.String(table),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{{AttributeName: aws.String("LockName"), AttributeType: aws.String("S")}},
+		KeySchema:            []*dynamodb.KeySchemaElement{{AttributeName: aws.String("LockName"), KeyType: aws.String("HASH")}},
+		BillingMode:          aws.String(dynamodb.BillingModePayPerRequest),
+	})
+	require.NoError(t, err)
+	defer db.DeleteTable(&dynamodb.DeleteTableInput{TableName: aws.String(table)})
+
+	a := NewDynamoDBLease(db, table, "cron", "a", time.Second)
+	b := NewDynamoDBLease(db, table, "cron", "b", time.Second)
+
+	leader, err := a.Acquire()
+	assert.NoError(t, err)
+	assert.True(t, leader)
+
+	leader, err = b.Acquire()
+	assert.NoError(t, err)
+	assert.False(t, leader)
+
+	// b takes over once a stops renewing its lease
+	time.Sleep(1100 * time.Millisecond)
+
+	leader, err = b.Acquire()
+	assert.NoError(t, err)
+	assert.True(t, leader)
+
+	leader, err = a.Acquire()
+	assert.NoError(t, err)
+	assert.False(t, leader)
+
+	assert.NoError(t, b.Release())
+
+	leader, err = a.Acquire()
+	assert.NoError(t, err)
+	assert.True(t, leader)
+}
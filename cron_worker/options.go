@@ -0,0 +1,105 @@
+package cron_worker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultRetryBackoff is the delay before the first retry of an entry, doubling with each further retry
+const defaultRetryBackoff = time.Second
+
+// cronMethods are the HTTP methods an entry can be requested with
+var cronMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// validate checks the per-entry options, normalising the method
+func (e *sqsCronItem) validate() error {
+	if "" != e.Method {
+		e.Method = strings.ToUpper(e.Method)
+		valid := false
+		for _, m := range cronMethods {
+			if m == e.Method {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unsupported method %q", e.Method)
+		}
+	}
+
+	for name := range e.Headers {
+		if "" == name || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	}
+
+	if "" != e.Body && !json.Valid([]byte(e.Body)) {
+		return errors.New("body is not valid JSON")
+	}
+
+	if e.Timeout < 0 {
+		return errors.New("timeout cannot be negative")
+	}
+
+	if e.Retries < 0 {
+		return errors.New("retries cannot be negative")
+	}
+
+	if e.RetryBackoff < 0 {
+		return errors.New("retry_backoff cannot be negative")
+	}
+
+	switch e.Catchup {
+	case "", CatchupNone, CatchupOnce:
+	default:
+		return fmt.Errorf("unknown catchup policy %q, expected %s or %s", e.Catchup, CatchupNone, CatchupOnce)
+	}
+
+	if e.CatchupWindow < 0 {
+		return errors.New("catchup_window cannot be negative")
+	}
+
+	if e.Jitter < 0 {
+		return errors.New("jitter cannot be negative")
+	}
+
+	if e.Spread < 0 {
+		return errors.New("spread cannot be negative")
+	}
+
+	return nil
+}
+
+func (e sqsCronItem) method() string {
+	if "" == e.Method {
+		return http.MethodPost
+	}
+	return e.Method
+}
+
+func (e sqsCronItem) retryBackoff() time.Duration {
+	if 0 == e.RetryBackoff {
+		return defaultRetryBackoff
+	}
+	return e.RetryBackoff
+}
+
+// shouldRetryCronRequest reports whether a failed request may succeed if tried again
+func shouldRetryCronRequest(res *http.Response, err error) bool {
+	if nil != err {
+		return true
+	}
+	return http.StatusTooManyRequests == res.StatusCode || res.StatusCode >= 500
+}
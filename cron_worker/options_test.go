@@ -0,0 +1,61 @@
+package cron_worker
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronEntryOptions(t *testing.T) {
+	requestCount := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+
+		assert.Equal(t, "PUT", r.Method)
+		assert.Equal(t, "/tasks/report", r.URL.Path)
+		assert.Equal(t, "report", r.Header.Get("X-Aws-Sqsd-Taskname"))
+		assert.Equal(t, "bar", r.Header.Get("X-Foo"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, `{"kind": "daily"}`, string(body))
+
+		// fail the first attempt so the entry is retried
+		if requestCount == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	entry := sqsCronItem{
+		Name:         "report",
+		Url:          "/tasks/report",
+		Method:       "put",
+		Headers:      map[string]string{"X-Foo": "bar"},
+		Body:         `{"kind": "daily"}`,
+		Timeout:      time.Second,
+		Retries:      2,
+		RetryBackoff: time.Millisecond,
+	}
+	assert.NoError(t, entry.validate())
+
+	w := &Worker{config: &Config{EndPoint: ts.URL, Timeout: time.Second}}
+	w.makeCronRequestFunc(entry)()
+
+	assert.Equal(t, 2, requestCount)
+}
+
+func TestCronEntryValidate(t *testing.T) {
+	assert.Error(t, (&sqsCronItem{Method: "FETCH"}).validate())
+	assert.Error(t, (&sqsCronItem{Headers: map[string]string{"X Foo": "bar"}}).validate())
+	assert.Error(t, (&sqsCronItem{Body: "{"}).validate())
+	assert.Error(t, (&sqsCronItem{Timeout: -time.Second}).validate())
+	assert.Error(t, (&sqsCronItem{Retries: -1}).validate())
+}
@@ -0,0 +1,86 @@
+package cron_worker
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// Overlap policies decide what happens when an entry fires while its previous run is still in progress
+const (
+	// OverlapAllow starts another run concurrently
+	OverlapAllow = "allow"
+	// OverlapSkip skips the run
+	OverlapSkip = "skip"
+	// OverlapQueue starts the run once the previous one has finished
+	OverlapQueue = "queue"
+)
+
+// skippedRuns counts runs skipped by OverlapSkip by entry name, published with the other expvar metrics
+var skippedRuns = expvar.NewMap("cron_skipped_runs")
+
+// overlapGuard runs a run of an entry according to its overlap policy. Scheduled and manually triggered runs
+// of an entry share the same guard
+type overlapGuard func(run func())
+
+// newOverlapGuard returns the guard implementing the entry's overlap policy
+func newOverlapGuard(entry sqsCronItem) (overlapGuard, error) {
+	switch entry.Overlap {
+	case "", OverlapAllow:
+		return func(run func()) { run() }, nil
+	case OverlapSkip:
+		return skipIfStillRunning(entry), nil
+	case OverlapQueue:
+		return delayIfStillRunning(entry), nil
+	default:
+		return nil, fmt.Errorf("unknown overlap policy %q, expected one of %s, %s or %s", entry.Overlap, OverlapAllow, OverlapSkip, OverlapQueue)
+	}
+}
+
+// wrapper returns a job wrapper running jobs through the guard
+func (g overlapGuard) wrapper() cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		return cron.FuncJob(func() { g(j.Run) })
+	}
+}
+
+// skipIfStillRunning is cron.SkipIfStillRunning, logging and counting skipped runs against the entry
+func skipIfStillRunning(entry sqsCronItem) overlapGuard {
+	ch := make(chan struct{}, 1)
+	ch <- struct{}{}
+	return func(run func()) {
+		select {
+		case v := <-ch:
+			defer func() { ch <- v }()
+			run()
+		default:
+			skippedRuns.Add(entry.Name, 1)
+			log.
+				WithField("what", "cron").
+				WithField("entry", entry.Name).
+				Warn("Previous run still in progress, skipping")
+		}
+	}
+}
+
+// delayIfStillRunning is cron.DelayIfStillRunning, logging runs delayed by over a minute against the entry
+func delayIfStillRunning(entry sqsCronItem) overlapGuard {
+	var mu sync.Mutex
+	return func(run func()) {
+		start := time.Now()
+		mu.Lock()
+		defer mu.Unlock()
+		if delay := time.Since(start); delay > time.Minute {
+			log.
+				WithField("what", "cron").
+				WithField("entry", entry.Name).
+				WithField("delay", delay).
+				Info("Previous run finished, starting delayed run")
+		}
+		run()
+	}
+}
@@ -0,0 +1,45 @@
+package cron_worker
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlapSkip(t *testing.T) {
+	entry := sqsCronItem{Name: "overlap-skip", Overlap: OverlapSkip}
+	guard, err := newOverlapGuard(entry)
+	assert.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	runs := 0
+	job := cron.NewChain(guard.wrapper()).Then(cron.FuncJob(func() {
+		runs++
+		close(started)
+		<-release
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		job.Run()
+	}()
+	<-started
+
+	// the first run is still in progress, so this one is skipped
+	job.Run()
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, "1", skippedRuns.Get("overlap-skip").String())
+}
+
+func TestOverlapUnknownPolicy(t *testing.T) {
+	_, err := newOverlapGuard(sqsCronItem{Name: "overlap-unknown", Overlap: "sometimes"})
+	assert.Error(t, err)
+}
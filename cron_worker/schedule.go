@@ -0,0 +1,63 @@
+package cron_worker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser accepts standard 5 field schedules, an optional leading seconds field, and descriptors such as
+// @hourly or @every 90s
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// applyDefaults sets the top-level timezone, jitter and spread on entries without their own, so that every
+// entry carries how it is scheduled
+func (c *sqsCron) applyDefaults() error {
+	if "" != c.Timezone {
+		if _, err := time.LoadLocation(c.Timezone); nil != err {
+			return fmt.Errorf("invalid timezone %q: %s", c.Timezone, err)
+		}
+	}
+
+	if c.Jitter < 0 {
+		return errors.New("jitter cannot be negative")
+	}
+
+	if c.Spread < 0 {
+		return errors.New("spread cannot be negative")
+	}
+
+	for idx := range c.Cron {
+		entry := &c.Cron[idx]
+		if "" == entry.Timezone {
+			entry.Timezone = c.Timezone
+		}
+		if 0 == entry.Jitter {
+			entry.Jitter = c.Jitter
+		}
+		if 0 == entry.Spread {
+			entry.Spread = c.Spread
+		}
+	}
+	return nil
+}
+
+// schedule parses the entry's schedule. Schedules without a timezone run in the local timezone
+func (e sqsCronItem) schedule() (cron.Schedule, error) {
+	spec := e.Schedule
+	if "" != e.Timezone && !strings.HasPrefix(spec, "CRON_TZ=") && !strings.HasPrefix(spec, "TZ=") {
+		if _, err := time.LoadLocation(e.Timezone); nil != err {
+			return nil, fmt.Errorf("invalid timezone %q: %s", e.Timezone, err)
+		}
+		spec = "CRON_TZ=" + e.Timezone + " " + spec
+	}
+
+	sched, err := cronParser.Parse(spec)
+	if nil != err {
+		return nil, fmt.Errorf("invalid schedule %q: %s", e.Schedule, err)
+	}
+	return sched, nil
+}
@@ -0,0 +1,61 @@
+package cron_worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronEntrySchedule(t *testing.T) {
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sched, err := sqsCronItem{Schedule: "30 * * * * *"}.schedule()
+	assert.NoError(t, err)
+	assert.Equal(t, from.Add(30*time.Second), sched.Next(from))
+
+	sched, err = sqsCronItem{Schedule: "@every 90s"}.schedule()
+	assert.NoError(t, err)
+	assert.Equal(t, from.Add(90*time.Second), sched.Next(from))
+
+	sched, err = sqsCronItem{Schedule: "0 9 * * *", Timezone: "America/New_York"}.schedule()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 1, 1, 14, 0, 0, 0, time.UTC), sched.Next(from).UTC())
+}
+
+func TestCronEntryScheduleErrors(t *testing.T) {
+	_, err := sqsCronItem{Schedule: "* * *"}.schedule()
+	assert.Error(t, err)
+
+	_, err = sqsCronItem{Schedule: "@every 90s", Timezone: "Mars/Olympus_Mons"}.schedule()
+	assert.Error(t, err)
+
+	assert.Error(t, (&sqsCron{Timezone: "Mars/Olympus_Mons"}).applyDefaults())
+}
+
+func TestCronTabDefaults(t *testing.T) {
+	crontab := &sqsCron{
+		Timezone: "Europe/London",
+		Jitter:   time.Minute,
+		Spread:   time.Hour,
+		Cron:     []sqsCronItem{{Name: "default"}, {Name: "own", Timezone: "Asia/Tokyo", Jitter: time.Second, Spread: time.Minute}},
+	}
+	assert.NoError(t, crontab.applyDefaults())
+
+	assert.Equal(t, "Europe/London", crontab.Cron[0].Timezone)
+	assert.Equal(t, time.Minute, crontab.Cron[0].Jitter)
+	assert.Equal(t, time.Hour, crontab.Cron[0].Spread)
+	assert.Equal(t, "Asia/Tokyo", crontab.Cron[1].Timezone)
+	assert.Equal(t, time.Second, crontab.Cron[1].Jitter)
+	assert.Equal(t, time.Minute, crontab.Cron[1].Spread)
+
+	assert.Error(t, (&sqsCron{Jitter: -time.Second}).applyDefaults())
+}
+
+func TestSpreadOffset(t *testing.T) {
+	offset := spreadOffset("report", time.Hour)
+	assert.True(t, offset >= 0 && offset < time.Hour)
+	assert.Equal(t, offset, spreadOffset("report", time.Hour))
+	assert.NotEqual(t, offset, spreadOffset("cleanup", time.Hour))
+	assert.Equal(t, time.Duration(0), spreadOffset("report", 0))
+}
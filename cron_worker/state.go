@@ -0,0 +1,188 @@
+package cron_worker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// Catch-up policies decide what happens to runs missed while no replica was running
+const (
+	// CatchupNone drops missed runs
+	CatchupNone = "none"
+	// CatchupOnce fires a single make-up run on startup when the last scheduled run was missed
+	CatchupOnce = "once"
+)
+
+type (
+	// StateStore persists when each entry last ran, so runs missed across restarts can be caught up
+	StateStore interface {
+		// LastRun returns when the entry last ran, and whether it ever did
+		LastRun(name string) (time.Time, bool, error)
+		SetLastRun(name string, t time.Time) error
+	}
+
+	// FileStateStore keeps the last run of each entry in a local JSON file
+	FileStateStore struct {
+		path string
+
+		mu sync.Mutex
+	}
+)
+
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path}
+}
+
+func (s *FileStateStore) LastRun(name string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.read()
+	if nil != err {
+		return time.Time{}, false, err
+	}
+
+	t, ok := state[name]
+	return t, ok, nil
+}
+
+func (s *FileStateStore) SetLastRun(name string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.read()
+	if nil != err {
+		return err
+	}
+	state[name] = t
+
+	contents, err := json.Marshal(state)
+	if nil != err {
+		return err
+	}
+
+	// write to a temporary file and rename it, so a crash never leaves a truncated state file
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if nil != err {
+		return err
+	}
+	if _, err = tmp.Write(contents); nil != err {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); nil != err {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *FileStateStore) read() (map[string]time.Time, error) {
+	state := make(map[string]time.Time)
+
+	contents, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(contents, &state); nil != err {
+		return nil, err
+	}
+	return state, nil
+}
+
+// recordRun stores when the entry ran
+func (w *Worker) recordRun(entry sqsCronItem, t time.Time) {
+	if nil == w.config.StateStore {
+		return
+	}
+
+	if err := w.config.StateStore.SetLastRun(entry.Name, t); nil != err {
+		log.
+			WithField("what", "cron").
+			WithField("entry", entry.Name).
+			WithError(err).
+			Error("Failed to record cron run")
+	}
+}
+
+// catchUp fires a single make-up run of entries with the CatchupOnce policy whose last scheduled run was
+// missed within their catch-up window before now. The state store is read without holding w.mu, as it may
+// be remote
+func (w *Worker) catchUp(now time.Time) {
+	if nil == w.config.StateStore {
+		return
+	}
+
+	type catchUpEntry struct {
+		entry sqsCronItem
+		sched cron.Schedule
+		job   cron.Job
+	}
+
+	var entries []catchUpEntry
+	w.mu.Lock()
+	if nil != w.crontab {
+		for _, entry := range w.crontab.Cron {
+			if CatchupOnce != entry.Catchup || 0 == entry.cronEntryId {
+				continue
+			}
+			scheduled := w.cron.Entry(entry.cronEntryId)
+			entries = append(entries, catchUpEntry{entry: entry, sched: scheduled.Schedule, job: scheduled.Job})
+		}
+	}
+	w.mu.Unlock()
+
+	for _, e := range entries {
+		entryLog := log.
+			WithField("what", "cron").
+			WithField("entry", e.entry.Name)
+
+		last, ok, err := w.config.StateStore.LastRun(e.entry.Name)
+		if nil != err {
+			entryLog.WithError(err).Error("Failed to read last cron run")
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		missed := lastMissedRun(e.sched, last, now)
+		if missed.IsZero() {
+			continue
+		}
+
+		window := e.entry.CatchupWindow
+		if 0 == window {
+			window = w.config.CatchupWindow
+		}
+		if now.Sub(missed) > window {
+			entryLog.WithField("missed", missed).Info("Missed cron run is outside the catch-up window, skipping")
+			continue
+		}
+
+		entryLog.WithField("missed", missed).Info("Catching up missed cron run")
+		go e.job.Run()
+	}
+}
+
+// lastMissedRun returns the latest time sched was due between last and now, or the zero time if none was
+func lastMissedRun(sched cron.Schedule, last time.Time, now time.Time) time.Time {
+	var missed time.Time
+	for next := sched.Next(last); !next.IsZero() && !next.After(now); next = sched.Next(next) {
+		missed = next
+	}
+	return missed
+}
@@ -0,0 +1,57 @@
+package cron_worker
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// DynamoDBStateStore keeps the last run of each entry in a DynamoDB table with an EntryName string partition
+// key, so it is shared by all replicas
+type DynamoDBStateStore struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+}
+
+func NewDynamoDBStateStore(db dynamodbiface.DynamoDBAPI, table string) *DynamoDBStateStore {
+	return &DynamoDBStateStore{db: db, table: table}
+}
+
+func (s *DynamoDBStateStore) LastRun(name string) (time.Time, bool, error) {
+	output, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"EntryName": {S: aws.String(name)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if nil != err {
+		return time.Time{}, false, err
+	}
+
+	lastRun, ok := output.Item["LastRun"]
+	if !ok || nil == lastRun.N {
+		return time.Time{}, false, nil
+	}
+
+	ms, err := strconv.ParseInt(*lastRun.N, 10, 64)
+	if nil != err {
+		return time.Time{}, false, err
+	}
+
+	return time.Unix(0, ms*int64(time.Millisecond)), true, nil
+}
+
+func (s *DynamoDBStateStore) SetLastRun(name string, t time.Time) error {
+	_, err := s.db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"EntryName": {S: aws.String(name)},
+			"LastRun":   {N: aws.String(strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10))},
+		},
+	})
+	return err
+}
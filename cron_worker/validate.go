@@ -0,0 +1,216 @@
+package cron_worker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// supportedVersion is the only cron.yaml version understood, as with Elastic Beanstalk
+const supportedVersion = 1
+
+var (
+	// yamlErrorLine matches the line number prefixing yaml errors
+	yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	// yamlUnknownField matches errors about fields rejected by strict decoding
+	yamlUnknownField = regexp.MustCompile(`^field (\S+) not found in type .*$`)
+)
+
+type (
+	// Problem is an error found in a cron file, on the line it was found on when known
+	Problem struct {
+		Line  int
+		Entry string
+		Err   error
+	}
+
+	// Problems is the error a cron file fails to load with, listing every problem found in it
+	Problems []Problem
+
+	// EntryPreview lists the next times an entry will fire
+	EntryPreview struct {
+		Name     string
+		Schedule string
+		Timezone string
+		Next     []time.Time
+	}
+)
+
+func (p Problem) Error() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s", p.Line, p.Message())
+	}
+	return p.Message()
+}
+
+// Message describes the problem without the line it was found on
+func (p Problem) Message() string {
+	if "" != p.Entry {
+		return fmt.Sprintf("entry %q: %s", p.Entry, p.Err)
+	}
+	return p.Err.Error()
+}
+
+func (p Problems) Error() string {
+	msgs := make([]string, len(p))
+	for idx, problem := range p {
+		msgs[idx] = problem.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// yamlProblem converts a yaml error message into a Problem, extracting its line number
+func yamlProblem(msg string) Problem {
+	line := 0
+	if m := yamlErrorLine.FindStringSubmatch(msg); nil != m {
+		line, _ = strconv.Atoi(m[1])
+		msg = m[2]
+	}
+	if m := yamlUnknownField.FindStringSubmatch(msg); nil != m {
+		msg = fmt.Sprintf("unknown field %q", m[1])
+	}
+	return Problem{Line: line, Err: errors.New(msg)}
+}
+
+// ValidateCronTab checks the contents of a cron file the way they are loaded, returning the next n times
+// each entry fires after from when there are no problems
+func ValidateCronTab(contents []byte, n int, from time.Time) ([]EntryPreview, []Problem) {
+	w := &Worker{config: &Config{}, cron: cron.New(cron.WithParser(cronParser))}
+
+	crontab, problems := w.checkCronTab(contents)
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	if err := w.loadCronEntries(crontab); nil != err {
+		return nil, []Problem{{Err: err}}
+	}
+
+	previews := make([]EntryPreview, 0, len(crontab.Cron))
+	for _, entry := range crontab.Cron {
+		preview := EntryPreview{Name: entry.Name, Schedule: entry.Schedule, Timezone: entry.Timezone}
+		sched := w.cron.Entry(entry.cronEntryId).Schedule
+		for next := from; len(preview.Next) < n; {
+			next = sched.Next(next)
+			if next.IsZero() {
+				break
+			}
+			preview.Next = append(preview.Next, next)
+		}
+		previews = append(previews, preview)
+	}
+	return previews, nil
+}
+
+// checkCronTab strictly parses a cron file, returning every problem found rather than stopping at the first
+func (w *Worker) checkCronTab(contents []byte) (*sqsCron, []Problem) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(contents, &doc); nil != err {
+		return nil, []Problem{yamlProblem(err.Error())}
+	}
+
+	var problems []Problem
+	crontab := &sqsCron{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(crontab); nil != err {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, []Problem{yamlProblem(err.Error())}
+		}
+		for _, msg := range typeErr.Errors {
+			problems = append(problems, yamlProblem(msg))
+		}
+	}
+
+	root := &doc
+	if yaml.DocumentNode == root.Kind && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+
+	if supportedVersion != crontab.Version {
+		problems = append(problems, Problem{
+			Line: keyLine(root, "version"),
+			Err:  fmt.Errorf("unsupported version %d, expected %d", crontab.Version, supportedVersion),
+		})
+	}
+
+	if err := crontab.applyDefaults(); nil != err {
+		problems = append(problems, Problem{Line: keyLine(root, "timezone"), Err: err})
+	}
+
+	var entryNodes []*yaml.Node
+	if entries := valueNode(root, "cron"); nil != entries {
+		entryNodes = entries.Content
+	}
+
+	names := make(map[string]int)
+	for idx := range crontab.Cron {
+		entry := &crontab.Cron[idx]
+		line := 0
+		if idx < len(entryNodes) {
+			line = entryNodes[idx].Line
+		}
+
+		problem := func(err error) {
+			problems = append(problems, Problem{Line: line, Entry: entry.Name, Err: err})
+		}
+
+		if "" == entry.Name {
+			problem(errors.New("name is required"))
+		} else if first, ok := names[entry.Name]; ok {
+			problem(fmt.Errorf("duplicate name, first used on line %d", first))
+		} else {
+			names[entry.Name] = line
+		}
+
+		if "" == entry.Url {
+			problem(errors.New("url is required"))
+		}
+
+		if _, _, err := w.prepareCronEntry(entry); nil != err {
+			problem(err)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+
+	return crontab, problems
+}
+
+// valueNode returns the value of key in a mapping node
+func valueNode(mapping *yaml.Node, key string) *yaml.Node {
+	if yaml.MappingNode != mapping.Kind {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if key == mapping.Content[i].Value {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// keyLine returns the line key is on in a mapping node, or 0 when it is missing
+func keyLine(mapping *yaml.Node, key string) int {
+	if yaml.MappingNode != mapping.Kind {
+		return 0
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if key == mapping.Content[i].Value {
+			return mapping.Content[i].Line
+		}
+	}
+	return 0
+}
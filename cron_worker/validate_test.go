@@ -0,0 +1,60 @@
+package cron_worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateCronTab(t *testing.T) {
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	previews, problems := ValidateCronTab([]byte(`version: 1
+timezone: "UTC"
+cron:
+  - name: "hourly"
+    url: "/hourly"
+    schedule: "0 * * * *"
+  - name: "heartbeat"
+    url: "/heartbeat"
+    schedule: "@every 90s"
+`), 2, from)
+
+	assert.Empty(t, problems)
+	require.Len(t, previews, 2)
+	assert.Equal(t, "hourly", previews[0].Name)
+	assert.Equal(t, "UTC", previews[0].Timezone)
+	assert.Equal(t, []time.Time{from.Add(time.Hour), from.Add(2 * time.Hour)}, previews[0].Next)
+	assert.Equal(t, []time.Time{from.Add(90 * time.Second), from.Add(180 * time.Second)}, previews[1].Next)
+}
+
+func TestValidateCronTabProblems(t *testing.T) {
+	_, problems := ValidateCronTab([]byte(`version: 2
+cron:
+  - name: "first"
+    url: "/first"
+    schedule: "0 * * * *"
+  - name: "first"
+    schedule: "bogus"
+    colour: "blue"
+`), 1, time.Now())
+
+	var messages []string
+	for _, problem := range problems {
+		messages = append(messages, problem.Error())
+	}
+
+	assert.Equal(t, []string{
+		"line 1: unsupported version 2, expected 1",
+		`line 6: entry "first": duplicate name, first used on line 3`,
+		`line 6: entry "first": url is required`,
+		`line 6: entry "first": invalid schedule "bogus": expected 5 to 6 fields, found 1: [bogus]`,
+		`line 8: unknown field "colour"`,
+	}, messages)
+
+	_, problems = ValidateCronTab([]byte("version: 1\ncron:\n  - name: [\n"), 1, time.Now())
+	require.Len(t, problems, 1)
+	assert.Equal(t, 3, problems[0].Line)
+}
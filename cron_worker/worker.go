@@ -1,47 +1,101 @@
 package cron_worker
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 	"github.com/fsnotify/fsnotify"
+	"github.com/fterrag/simple-sqsd/secret"
 	"github.com/robfig/cron/v3"
 	log "github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v2"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 )
 
+// reloadDebounce is how long the cron file's directory must be quiet before the file is reloaded, so that
+// bursts of events from a single update cause one reload
+const reloadDebounce = 500 * time.Millisecond
+
 type (
 	Config struct {
 		File                        string
 		EndPoint                    string
 		Timeout                     time.Duration
+		Transport                   http.RoundTripper
 		UserAgent                   string
 		HTTPAUTHORIZATIONHeader     string
+		HTTPAUTHORIZATIONHeaderFile *secret.File
 		HTTPAUTHORIZATIONHeaderName string
 		HTTPContentType             string
+		TokenSource                 TokenSource
+		LeaderElector               LeaderElector
+		LeaderElectionInterval      time.Duration
+		// SQS and QueueURL, when set, make entries enqueue periodic task messages instead of calling EndPoint
+		SQS      sqsiface.SQSAPI
+		QueueURL string
+		// StateStore, when set, records when entries run so that entries with a catchup policy can make up
+		// runs missed within CatchupWindow on startup
+		StateStore    StateStore
+		CatchupWindow time.Duration
+	}
+	// TokenSource provides bearer tokens for the authorization header, see oauth.ClientCredentials
+	TokenSource interface {
+		Token() (string, error)
+		Invalidate(token string)
 	}
 	Worker struct {
 		config  *Config
 		crontab *sqsCron
 
-		cron *cron.Cron
+		cron     *cron.Cron
+		contents []byte
+		running  bool
 
 		fsDoneChan chan bool
 
+		electDoneChan chan bool
+		electOnce     sync.Once
+		leader        bool
+		leaderMu      sync.Mutex
+
+		history  map[string][]RunResult
+		paused   map[string]bool
+		statusMu sync.Mutex
+
 		mu sync.Mutex
 	}
 	sqsCronItem struct {
-		Name        string `yaml:"name"`
-		Url         string `yaml:"url"`
-		Schedule    string `yaml:"schedule"`
-		cronEntryId cron.EntryID
+		Name          string            `yaml:"name"`
+		Url           string            `yaml:"url"`
+		Schedule      string            `yaml:"schedule"`
+		Overlap       string            `yaml:"overlap"`
+		Timezone      string            `yaml:"timezone"`
+		Method        string            `yaml:"method"`
+		Headers       map[string]string `yaml:"headers"`
+		Body          string            `yaml:"body"`
+		Timeout       time.Duration     `yaml:"timeout"`
+		Retries       int               `yaml:"retries"`
+		RetryBackoff  time.Duration     `yaml:"retry_backoff"`
+		Catchup       string            `yaml:"catchup"`
+		CatchupWindow time.Duration     `yaml:"catchup_window"`
+		Jitter        time.Duration     `yaml:"jitter"`
+		Spread        time.Duration     `yaml:"spread"`
+		cronEntryId   cron.EntryID
+		guard         overlapGuard
 	}
 	sqsCron struct {
-		Version int `yaml:"version"`
-		Cron    []sqsCronItem
+		Version  int           `yaml:"version"`
+		Timezone string        `yaml:"timezone"`
+		Jitter   time.Duration `yaml:"jitter"`
+		Spread   time.Duration `yaml:"spread"`
+		Cron     []sqsCronItem
 	}
 )
 
@@ -55,11 +109,21 @@ func New(c *Config) *Worker {
 		c.Timeout = 30 * time.Second
 	}
 
+	if c.LeaderElectionInterval.Seconds() < 1 {
+		c.LeaderElectionInterval = 5 * time.Second
+	}
+
+	if c.CatchupWindow.Seconds() < 1 {
+		c.CatchupWindow = time.Hour
+	}
+
 	wkr := Worker{
 		config:  c,
 		crontab: nil,
 	}
 	wkr.fsDoneChan = make(chan bool, 10)
+	wkr.electDoneChan = make(chan bool, 1)
+	wkr.cron = cron.New(cron.WithParser(cronParser))
 	wkr.loadCronTab()
 
 	return &wkr
@@ -67,100 +131,165 @@ func New(c *Config) *Worker {
 
 // Run handles the running of the cron tab and the watching of the cron yaml file and reloading when required
 func (w *Worker) Run() {
-	if nil == w.cron {
-		log.Errorf("Cannot run cron")
-		return
-	}
+	w.mu.Lock()
+	w.running = true
 	w.cron.Start()
-
-	for _, entry := range w.crontab.Cron {
-		log.
-			WithField("what", "cron").
-			WithField("next", w.cron.Entry(entry.cronEntryId).Next).
-			WithField("name", entry.Name).
-			Debug("Next Occurrence")
+	if nil != w.crontab {
+		w.logNextOccurrences()
+	} else {
+		log.WithField("file", w.config.File).Error("No valid crontab loaded, waiting for the cron file to change")
+	}
+	w.mu.Unlock()
+
+	if nil != w.config.LeaderElector {
+		// missed runs are only made up by the leader, when it is elected
+		w.electOnce.Do(func() {
+			w.campaign()
+			go w.elect()
+		})
+	} else {
+		w.catchUp(time.Now())
 	}
 
+	// the parent directory is watched so that files replaced by a rename, or by a Kubernetes ConfigMap
+	// symlink swap, are also picked up
 	watcher, err := fsnotify.NewWatcher()
 	if nil != err {
 		log.WithError(err).WithField("file", w.config.File).Error("Unable to watch cron file for changes")
 		return
 	}
-	err = watcher.Add(w.config.File)
+	err = watcher.Add(filepath.Dir(w.config.File))
 	if nil != err {
+		watcher.Close()
 		log.WithError(err).WithField("file", w.config.File).Error("Unable to watch cron file for changes")
 		return
 	}
 
-	go func() {
-		defer func() {
-			if err = watcher.Close(); nil != err {
-				log.WithError(err).Error("error closing fs watcher")
+	go w.watch(watcher)
+}
+
+// watch reloads the crontab once events on the cron file's directory have settled for reloadDebounce
+func (w *Worker) watch(watcher *fsnotify.Watcher) {
+	defer func() {
+		if err := watcher.Close(); nil != err {
+			log.WithError(err).Error("error closing fs watcher")
+		}
+	}()
+
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-w.fsDoneChan:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if 0 != event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) {
+				debounce.Reset(reloadDebounce)
 			}
-		}()
-		for {
-			select {
-			case <-w.fsDoneChan:
+		case <-debounce.C:
+			w.reloadCronTab()
+		case err, ok := <-watcher.Errors:
+			if !ok {
 				return
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					log.WithField("file", w.config.File).Info("cron file changed. reloading")
-					w.loadCronTab()
-					w.Run()
-					return
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				log.WithError(err).Error("FSNotify Error")
 			}
+			log.WithError(err).Error("FSNotify Error")
 		}
-	}()
+	}
 }
 
 // Stop handles shutting down the cron worker safely
 func (w *Worker) Stop() {
 	w.fsDoneChan <- true
-	if nil != w.cron {
-		w.cron.Stop()
+	if nil != w.config.LeaderElector {
+		w.electDoneChan <- true
 	}
-}
 
-// loadCronTab is the parent method that reads, parses and then loads the crontab
-func (w *Worker) loadCronTab() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	if nil != w.cron {
-		w.cron.Stop()
-		w.cron = nil
+	w.running = false
+	w.cron.Stop()
+}
+
+// reloadCronTab loads the cron file again if its contents changed
+func (w *Worker) reloadCronTab() {
+	contents, err := w.readCronTab(w.config.File)
+	if nil != err {
+		log.WithError(err).WithField("file", w.config.File).Error("Failed to read crontab, keeping the current schedule")
+		return
 	}
 
+	w.mu.Lock()
+	unchanged := bytes.Equal(contents, w.contents)
+	w.mu.Unlock()
+	if unchanged {
+		return
+	}
+
+	log.WithField("file", w.config.File).Info("cron file changed. reloading")
+	w.loadCronTab()
+}
+
+// loadCronTab is the parent method that reads, parses and then loads the crontab. The current crontab keeps
+// running unless the new one loads successfully
+func (w *Worker) loadCronTab() {
 	contents, err := w.readCronTab(w.config.File)
 	if nil != err {
 		log.WithError(err).Error("Failed to load crontab")
 		return
 	}
 
-	if err = w.parseCronTab(contents); nil != err {
-		log.WithError(err).Error("Failed to parse crontab")
+	crontab, err := w.parseCronTab(contents)
+	if nil != err {
+		var problems Problems
+		if errors.As(err, &problems) {
+			for _, problem := range problems {
+				log.
+					WithField("file", w.config.File).
+					WithField("line", problem.Line).
+					WithField("entry", problem.Entry).
+					WithError(problem.Err).
+					Error("Invalid crontab")
+			}
+		}
+		log.WithError(err).Error("Failed to parse crontab, keeping the current schedule")
 		return
 	}
 
 	// log some info about our crontab
 	log.
 		WithField("file", w.config.File).
-		WithField("version", w.crontab.Version).
-		WithField("num-entries", len(w.crontab.Cron)).
+		WithField("version", crontab.Version).
+		WithField("num-entries", len(crontab.Cron)).
 		Info("EBS Crontab Info")
 
-	if err = w.loadCronEntries(); nil != err {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err = w.loadCronEntries(crontab); nil != err {
 		log.WithError(err).Error("Failed to start crontab")
 		return
 	}
+	w.crontab = crontab
+	w.contents = contents
+
+	if w.running {
+		w.logNextOccurrences()
+	}
+}
+
+func (w *Worker) logNextOccurrences() {
+	for _, entry := range w.crontab.Cron {
+		log.
+			WithField("what", "cron").
+			WithField("next", w.cron.Entry(entry.cronEntryId).Next).
+			WithField("name", entry.Name).
+			Debug("Next Occurrence")
+	}
 }
 
 func (w *Worker) readCronTab(path string) ([]byte, error) {
@@ -168,101 +297,332 @@ func (w *Worker) readCronTab(path string) ([]byte, error) {
 	if nil != err {
 		return nil, err
 	}
+	defer fh.Close()
 
 	return io.ReadAll(fh)
 }
 
-func (w *Worker) parseCronTab(contents []byte) error {
-	crontab := sqsCron{}
-
-	err := yaml.Unmarshal(contents, &crontab)
-	if nil != err {
-		return err
+// parseCronTab strictly parses a cron file, failing with every problem found in it
+func (w *Worker) parseCronTab(contents []byte) (*sqsCron, error) {
+	crontab, problems := w.checkCronTab(contents)
+	if len(problems) > 0 {
+		return nil, Problems(problems)
 	}
 
-	w.crontab = &crontab
-	return nil
+	return crontab, nil
 }
 
-func (w *Worker) loadCronEntries() error {
-	if nil == w.crontab {
+// loadCronEntries schedules the crontab's entries on the running cron by comparing them to the current
+// crontab by name. Unchanged entries are left alone, so their next run and any run in progress are kept.
+// Every entry is prepared before the cron is touched, so an invalid crontab leaves the current one running
+func (w *Worker) loadCronEntries(crontab *sqsCron) error {
+	if nil == crontab {
 		return errors.New("please parse a crontab before loading it")
 	}
 
-	w.cron = cron.New()
+	if err := crontab.applyDefaults(); nil != err {
+		return err
+	}
 
-	for idx, entry := range w.crontab.Cron {
-		entryId, err := w.cron.AddFunc(entry.Schedule, w.makeCronRequestFunc(entry))
-		if err != nil {
-			log.
-				WithField("what", "cron").
-				WithError(err).
-				WithField("entry", entry.Name).
-				Error("Failed to load cron entry")
+	current := make(map[string]sqsCronItem)
+	if nil != w.crontab {
+		for _, entry := range w.crontab.Cron {
+			if 0 != entry.cronEntryId {
+				current[entry.Name] = entry
+			}
+		}
+	}
+
+	type change struct {
+		entry *sqsCronItem
+		sched cron.Schedule
+		job   cron.Job
+	}
+
+	seen := make(map[string]bool)
+	var changes []change
+
+	for idx := range crontab.Cron {
+		entry := &crontab.Cron[idx]
+
+		if seen[entry.Name] {
+			return fmt.Errorf("duplicate cron entry name %q", entry.Name)
+		}
+		seen[entry.Name] = true
+
+		if old, exists := current[entry.Name]; exists && old.equal(*entry) {
+			entry.cronEntryId = old.cronEntryId
+			entry.guard = old.guard
 			continue
 		}
-		w.crontab.Cron[idx].cronEntryId = entryId
+
+		sched, job, err := w.prepareCronEntry(entry)
+		if nil != err {
+			return fmt.Errorf("cron entry %q: %s", entry.Name, err)
+		}
+		changes = append(changes, change{entry: entry, sched: sched, job: job})
+	}
+
+	unchanged := len(crontab.Cron) - len(changes)
+	var added, updated int
+	for _, c := range changes {
+		entryLog := log.
+			WithField("what", "cron").
+			WithField("entry", c.entry.Name).
+			WithField("schedule", c.entry.Schedule)
+
+		if old, exists := current[c.entry.Name]; exists {
+			w.cron.Remove(old.cronEntryId)
+			delete(current, c.entry.Name)
+			entryLog.Info("Cron entry updated")
+			updated++
+		} else {
+			entryLog.Info("Cron entry added")
+			added++
+		}
+		c.entry.cronEntryId = w.cron.Schedule(c.sched, c.job)
 	}
 
+	removed := 0
+	for name, old := range current {
+		if !crontab.scheduled(name) {
+			w.cron.Remove(old.cronEntryId)
+			log.WithField("what", "cron").WithField("entry", name).Info("Cron entry removed")
+			removed++
+		}
+	}
+
+	log.
+		WithField("what", "cron").
+		WithField("added", added).
+		WithField("updated", updated).
+		WithField("removed", removed).
+		WithField("unchanged", unchanged).
+		Info("Crontab loaded")
+
 	return nil
 }
 
-func (w *Worker) makeCronRequestFunc(entry sqsCronItem) func() {
-	cronUrl := w.config.EndPoint + entry.Url
-	return func() {
-		t1 := time.Now()
-		rqLog := log.
-			WithField("what", "cron").
-			WithField("entry", entry.Name).
-			WithField("url", cronUrl).
-			WithField("start", t1)
+// scheduled reports whether the entry with the given name was scheduled
+func (c *sqsCron) scheduled(name string) bool {
+	for _, entry := range c.Cron {
+		if name == entry.Name && 0 != entry.cronEntryId {
+			return true
+		}
+	}
+	return false
+}
 
-		rqLog.Debug("Requesting Cron URL")
+// equal reports whether two entries have the same definition
+func (e sqsCronItem) equal(other sqsCronItem) bool {
+	e.cronEntryId, e.guard = 0, nil
+	other.cronEntryId, other.guard = 0, nil
+	return reflect.DeepEqual(e, other)
+}
 
-		client := &http.Client{}
-		client.Timeout = w.config.Timeout
+// prepareCronEntry validates the entry and parses its schedule, returning the job to run on it
+func (w *Worker) prepareCronEntry(entry *sqsCronItem) (cron.Schedule, cron.Job, error) {
+	if err := entry.validate(); nil != err {
+		return nil, nil, err
+	}
 
-		//resp, err := client.Post(cronUrl, "application/json", nil)
-		req, err := http.NewRequest("POST", cronUrl, nil)
-		req.Header.Add("X-Aws-Sqsd-Taskname", entry.Name)
+	sched, err := entry.schedule()
+	if nil != err {
+		return nil, nil, err
+	}
 
-		if len(w.config.HTTPAUTHORIZATIONHeader) > 0 {
-			headerName := w.config.HTTPAUTHORIZATIONHeaderName
-			if len(headerName) == 0 {
-				headerName = "Authorization"
-			}
-			req.Header.Set(headerName, w.config.HTTPAUTHORIZATIONHeader)
+	guard, err := newOverlapGuard(*entry)
+	if nil != err {
+		return nil, nil, err
+	}
+	entry.guard = guard
+
+	return sched, cron.NewChain(guard.wrapper(), delayWrapper(*entry)).Then(cron.FuncJob(w.makeCronRequestFunc(*entry))), nil
+}
+
+// authorizationHeader returns the current authorization header value, preferring a bearer token and then the reloadable file
+func (w *Worker) authorizationHeader() (string, error) {
+	if nil != w.config.TokenSource {
+		token, err := w.config.TokenSource.Token()
+		if nil != err {
+			return "", err
 		}
-	
-		if len(w.config.HTTPContentType) > 0 {
-			req.Header.Set("Content-Type", w.config.HTTPContentType)
+		return "Bearer " + token, nil
+	}
+
+	if nil != w.config.HTTPAUTHORIZATIONHeaderFile {
+		return w.config.HTTPAUTHORIZATIONHeaderFile.String(), nil
+	}
+
+	return w.config.HTTPAUTHORIZATIONHeader, nil
+}
+
+// doCronRequest requests the entry, refreshing a rejected bearer token
+func (w *Worker) doCronRequest(client *http.Client, entry sqsCronItem, cronUrl string) (*http.Response, error) {
+	authorization, err := w.authorizationHeader()
+	if nil != err {
+		return nil, err
+	}
+
+	res, err := w.sendCronRequest(client, entry, cronUrl, authorization)
+
+	// a rejected token may have been revoked or expired early, so retry once with a fresh one
+	if nil == err && http.StatusUnauthorized == res.StatusCode && nil != w.config.TokenSource {
+		res.Body.Close()
+		log.WithField("what", "cron").WithField("entry", entry.Name).Debug("Cron request unauthorized, refreshing token")
+		w.config.TokenSource.Invalidate(strings.TrimPrefix(authorization, "Bearer "))
+
+		if authorization, err = w.authorizationHeader(); nil != err {
+			return nil, err
+		}
+		return w.sendCronRequest(client, entry, cronUrl, authorization)
+	}
+
+	return res, err
+}
+
+// sendCronRequest makes a single request for the entry with the given authorization header
+func (w *Worker) sendCronRequest(client *http.Client, entry sqsCronItem, cronUrl string, authorization string) (*http.Response, error) {
+	var body io.Reader
+	if "" != entry.Body {
+		body = strings.NewReader(entry.Body)
+	}
+
+	req, err := http.NewRequest(entry.method(), cronUrl, body)
+	if nil != err {
+		return nil, err
+	}
+	req.Header.Add("X-Aws-Sqsd-Taskname", entry.Name)
+
+	if len(authorization) > 0 {
+		headerName := w.config.HTTPAUTHORIZATIONHeaderName
+		if len(headerName) == 0 {
+			headerName = "Authorization"
 		}
+		req.Header.Set(headerName, authorization)
+	}
+
+	if len(w.config.HTTPContentType) > 0 {
+		req.Header.Set("Content-Type", w.config.HTTPContentType)
+	} else if "" != entry.Body {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if len(w.config.UserAgent) > 0 {
+		req.Header.Set("User-Agent", w.config.UserAgent)
+	}
+
+	for name, value := range entry.Headers {
+		req.Header.Set(name, value)
+	}
+
+	return client.Do(req)
+}
 
-		if len(w.config.UserAgent) > 0 {
-			req.Header.Set("User-Agent", w.config.UserAgent)
+func (w *Worker) makeCronRequestFunc(entry sqsCronItem) func() {
+	return func() {
+		if !w.isLeader() {
+			log.
+				WithField("what", "cron").
+				WithField("entry", entry.Name).
+				Debug("Not the cron leader, skipping")
+			return
 		}
 
-		res, err := client.Do(req)
-	
-		t2 := time.Now()
-		dur := t2.Sub(t1)
-		rqLog = rqLog.WithField("duration", dur.String())
-		if err != nil {
-			rqLog.
-				WithError(err).
-				Error("Failed Requesting Endpoint")
-				return
+		if w.isPaused(entry.Name) {
+			log.
+				WithField("what", "cron").
+				WithField("entry", entry.Name).
+				Info("Cron entry paused, skipping")
+			return
 		}
-		rqLog = rqLog.WithField("http-status", res.StatusCode)
 
-		if res.StatusCode < 200 || res.StatusCode > 299 {
-			rqLog.
-				Error("Requesting cron endpoint resulted in non 2XX Status Code")
+		w.runCronEntry(entry)
+	}
+}
+
+// runCronEntry runs the entry once, recording the outcome in its history
+func (w *Worker) runCronEntry(entry sqsCronItem) {
+	start := time.Now()
+	w.recordRun(entry, start)
+
+	var statusCode int
+	var err error
+	if nil != w.config.SQS && "" != w.config.QueueURL {
+		err = w.enqueueCronTask(entry)
+	} else {
+		statusCode, err = w.requestCronEntry(entry)
+	}
+
+	result := RunResult{
+		Start:      start,
+		Duration:   time.Since(start).String(),
+		StatusCode: statusCode,
+	}
+	if nil != err {
+		result.Error = err.Error()
+	}
+	w.recordResult(entry.Name, result)
+}
+
+// requestCronEntry calls the entry's URL, retrying as configured, and returns the final status code
+func (w *Worker) requestCronEntry(entry sqsCronItem) (int, error) {
+	cronUrl := w.config.EndPoint + entry.Url
+
+	t1 := time.Now()
+	rqLog := log.
+		WithField("what", "cron").
+		WithField("entry", entry.Name).
+		WithField("url", cronUrl).
+		WithField("start", t1)
+
+	rqLog.Debug("Requesting Cron URL")
+
+	client := &http.Client{Transport: w.config.Transport}
+	client.Timeout = w.config.Timeout
+	if entry.Timeout > 0 {
+		client.Timeout = entry.Timeout
+	}
+
+	res, err := w.doCronRequest(client, entry, cronUrl)
+
+	backoff := entry.retryBackoff()
+	for attempt := 1; attempt <= entry.Retries && shouldRetryCronRequest(res, err); attempt++ {
+		retryLog := rqLog.
+			WithField("attempt", attempt).
+			WithField("backoff", backoff.String())
+		if nil != err {
+			retryLog = retryLog.WithError(err)
 		} else {
-			rqLog.Info("Cron Success")
+			retryLog = retryLog.WithField("http-status", res.StatusCode)
+			res.Body.Close()
 		}
+		retryLog.Warn("Cron request failed, retrying")
+		time.Sleep(backoff)
+		backoff *= 2
 
-		res.Body.Close()
+		res, err = w.doCronRequest(client, entry, cronUrl)
+	}
+
+	t2 := time.Now()
+	dur := t2.Sub(t1)
+	rqLog = rqLog.WithField("duration", dur.String())
+	if err != nil {
+		rqLog.
+			WithError(err).
+			Error("Failed Requesting Endpoint")
+		return 0, err
+	}
+	rqLog = rqLog.WithField("http-status", res.StatusCode)
 
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		rqLog.
+			Error("Requesting cron endpoint resulted in non 2XX Status Code")
+	} else {
+		rqLog.Info("Cron Success")
 	}
+
+	res.Body.Close()
+
+	return res.StatusCode, nil
 }
@@ -0,0 +1,208 @@
+package cron_worker
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCronTab = `version: 1
+cron:
+  - name: "first"
+    url: "/first"
+    schedule: "0 * * * *"
+`
+
+func entryNames(w *Worker) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var names []string
+	if nil != w.crontab {
+		for _, entry := range w.crontab.Cron {
+			names = append(names, entry.Name)
+		}
+	}
+	return names
+}
+
+func TestWorkerReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cron.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(testCronTab), 0644))
+
+	w := New(&Config{File: path, EndPoint: "http://localhost"})
+	w.Run()
+	defer w.Stop()
+
+	assert.Equal(t, []string{"first"}, entryNames(w))
+
+	// replace the file with a rename, as editors and Kubernetes do
+	tmp := filepath.Join(dir, ".cron.yaml.tmp")
+	require.NoError(t, ioutil.WriteFile(tmp, []byte(testCronTab+`  - name: "second"
+    url: "/second"
+    schedule: "@every 90s"
+`), 0644))
+	require.NoError(t, os.Rename(tmp, path))
+
+	assert.Eventually(t, func() bool {
+		return 2 == len(entryNames(w))
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// an invalid file keeps the current schedule running
+	require.NoError(t, ioutil.WriteFile(path, []byte("cron: [\n"), 0644))
+	time.Sleep(2 * reloadDebounce)
+	assert.Equal(t, []string{"first", "second"}, entryNames(w))
+
+	require.NoError(t, os.Remove(path))
+	time.Sleep(2 * reloadDebounce)
+	assert.Equal(t, []string{"first", "second"}, entryNames(w))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(testCronTab), 0644))
+	assert.Eventually(t, func() bool {
+		return 1 == len(entryNames(w))
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestWorkerLoadCronEntriesDiff(t *testing.T) {
+	w := &Worker{config: &Config{EndPoint: "http://localhost"}, cron: cron.New(cron.WithParser(cronParser))}
+
+	load := func(entries ...sqsCronItem) *sqsCron {
+		crontab := &sqsCron{Version: 1, Cron: entries}
+		require.NoError(t, w.loadCronEntries(crontab))
+		w.crontab = crontab
+		return crontab
+	}
+
+	first := sqsCronItem{Name: "first", Url: "/first", Schedule: "0 * * * *"}
+	second := sqsCronItem{Name: "second", Url: "/second", Schedule: "0 * * * *"}
+	crontab := load(first, second)
+	firstID, secondID := crontab.Cron[0].cronEntryId, crontab.Cron[1].cronEntryId
+
+	second.Schedule = "30 * * * *"
+	third := sqsCronItem{Name: "third", Url: "/third", Schedule: "@every 90s"}
+	crontab = load(first, second, third)
+
+	assert.Equal(t, firstID, crontab.Cron[0].cronEntryId)
+	assert.NotEqual(t, secondID, crontab.Cron[1].cronEntryId)
+	assert.Len(t, w.cron.Entries(), 3)
+
+	crontab = load(first, third)
+
+	assert.Equal(t, firstID, crontab.Cron[0].cronEntryId)
+	assert.Len(t, w.cron.Entries(), 2)
+}
+
+func TestWorkerLoadCronEntriesAtomic(t *testing.T) {
+	w := &Worker{config: &Config{EndPoint: "http://localhost"}, cron: cron.New(cron.WithParser(cronParser))}
+
+	first := sqsCronItem{Name: "first", Url: "/first", Schedule: "0 * * * *"}
+	w.crontab = &sqsCron{Version: 1, Cron: []sqsCronItem{first}}
+	require.NoError(t, w.loadCronEntries(w.crontab))
+	firstID := w.crontab.Cron[0].cronEntryId
+
+	changed := first
+	changed.Schedule = "30 * * * *"
+	invalid := sqsCronItem{Name: "second", Url: "/second", Schedule: "bogus"}
+	assert.Error(t, w.loadCronEntries(&sqsCron{Version: 1, Cron: []sqsCronItem{changed, invalid}}))
+
+	duplicate := sqsCronItem{Name: "third", Url: "/third", Schedule: "0 * * * *"}
+	assert.Error(t, w.loadCronEntries(&sqsCron{Version: 1, Cron: []sqsCronItem{changed, duplicate, duplicate}}))
+
+	require.Len(t, w.cron.Entries(), 1)
+	assert.Equal(t, firstID, w.cron.Entries()[0].ID)
+}
+
+func TestWorkerLoadCronTabStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cron.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(testCronTab), 0644))
+
+	w := New(&Config{File: path, EndPoint: "http://localhost"})
+	require.Equal(t, []string{"first"}, entryNames(w))
+
+	for _, contents := range []string{
+		"version: 2\ncron: []\n",
+		testCronTab + "    colour: \"blue\"\n",
+		testCronTab + "  - name: \"first\"\n    url: \"/again\"\n    schedule: \"0 * * * *\"\n",
+		testCronTab + "  - name: \"second\"\n    schedule: \"0 * * * *\"\n",
+		testCronTab + "  - name: \"second\"\n    url: \"/second\"\n    schedule: \"bogus\"\n",
+	} {
+		require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+		w.loadCronTab()
+		assert.Equal(t, []string{"first"}, entryNames(w), contents)
+		assert.Len(t, w.cron.Entries(), 1, contents)
+	}
+}
+
+func TestWorkerCatchUp(t *testing.T) {
+	requests := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.URL.Path
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cron.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`version: 1
+cron:
+  - name: "missed"
+    url: "/missed"
+    schedule: "0 * * * *"
+    catchup: once
+  - name: "expired"
+    url: "/expired"
+    schedule: "@every 90m"
+    catchup: once
+    catchup_window: 1s
+  - name: "dropped"
+    url: "/dropped"
+    schedule: "0 * * * *"
+`), 0644))
+
+	// "missed" was due at the top of one of the last two hours, within the default window of an hour, while
+	// "expired" was due 30 minutes ago, well outside its window, whatever the time the test runs at
+	store := NewFileStateStore(filepath.Join(dir, "state.json"))
+	twoHoursAgo := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"missed", "expired", "dropped"} {
+		require.NoError(t, store.SetLastRun(name, twoHoursAgo))
+	}
+
+	w := New(&Config{File: path, EndPoint: ts.URL, StateStore: store})
+	w.Run()
+	defer w.Stop()
+
+	select {
+	case path := <-requests:
+		assert.Equal(t, "/missed", path)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "missed run was not caught up")
+	}
+
+	assert.Eventually(t, func() bool {
+		last, ok, err := store.LastRun("missed")
+		return nil == err && ok && last.After(twoHoursAgo)
+	}, 5*time.Second, 50*time.Millisecond)
+
+	select {
+	case path := <-requests:
+		assert.Fail(t, "unexpected catch-up run", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLastMissedRun(t *testing.T) {
+	sched, err := sqsCronItem{Schedule: "0 * * * *"}.schedule()
+	require.NoError(t, err)
+
+	last := time.Date(2021, 1, 1, 10, 0, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2021, 1, 1, 12, 0, 0, 0, time.Local), lastMissedRun(sched, last, last.Add(150*time.Minute)))
+	assert.True(t, lastMissedRun(sched, last, last.Add(30*time.Minute)).IsZero())
+}
@@ -0,0 +1,129 @@
+// Package oauth fetches OAuth 2.0 access tokens for requests made to the backend.
+package oauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expiryDelta is how long before their expiry tokens are refreshed, so that they do not expire in flight.
+// Short-lived tokens are refreshed after half of their lifetime instead.
+const expiryDelta = 30 * time.Second
+
+// ClientCredentials fetches tokens using the client credentials grant (RFC 6749 section 4.4), caching
+// them until shortly before they expire.
+type ClientCredentials struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	client       *http.Client
+
+	mu        sync.Mutex
+	token     string
+	refreshAt time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func NewClientCredentials(client *http.Client, tokenURL string, clientID string, clientSecret string, scopes []string) *ClientCredentials {
+	return &ClientCredentials{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		client:       client,
+	}
+}
+
+// Token returns a valid access token, fetching a new one when the cached token is missing or about to expire.
+func (c *ClientCredentials) Token() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.token) > 0 && (c.refreshAt.IsZero() || time.Now().Before(c.refreshAt)) {
+		return c.token, nil
+	}
+
+	token, refreshAt, err := c.fetch()
+	if err != nil {
+		return "", err
+	}
+
+	c.token = token
+	c.refreshAt = refreshAt
+
+	return c.token, nil
+}
+
+// Invalidate discards the cached token if it is still token, e.g. after it was rejected, so the next call to
+// Token fetches a new one. Tokens fetched since token was handed out are kept.
+func (c *ClientCredentials) Invalidate(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+func (c *ClientCredentials) fetch() (string, time.Time, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+
+	req, err := http.NewRequest("POST", c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("Error while creating token request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("Error while requesting token: %s", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("Error while reading token response: %s", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("Token endpoint responded with status code %d: %s", res.StatusCode, body)
+	}
+
+	t := &tokenResponse{}
+	if err := json.Unmarshal(body, t); err != nil {
+		return "", time.Time{}, fmt.Errorf("Error while parsing token response: %s", err)
+	}
+
+	if len(t.AccessToken) == 0 {
+		return "", time.Time{}, fmt.Errorf("Token response did not contain an access token")
+	}
+
+	var refreshAt time.Time
+	if t.ExpiresIn > 0 {
+		lifetime := time.Duration(t.ExpiresIn) * time.Second
+		delta := expiryDelta
+		if delta > lifetime/2 {
+			delta = lifetime / 2
+		}
+		refreshAt = time.Now().Add(lifetime - delta)
+	}
+
+	return t.AccessToken, refreshAt, nil
+}
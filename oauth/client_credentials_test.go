@@ -0,0 +1,98 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCredentials(t *testing.T) {
+	requestCount := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+
+		clientID, clientSecret, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "id", clientID)
+		assert.Equal(t, "secret", clientSecret)
+		assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+		assert.Equal(t, "read write", r.FormValue("scope"))
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "token%d", "token_type": "Bearer", "expires_in": 3600}`, requestCount)
+	}))
+	defer ts.Close()
+
+	c := NewClientCredentials(&http.Client{}, ts.URL, "id", "secret", []string{"read", "write"})
+
+	token, err := c.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "token1", token)
+
+	token, err = c.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "token1", token)
+	assert.Equal(t, 1, requestCount)
+
+	c.Invalidate("token1")
+
+	token, err = c.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "token2", token)
+	assert.Equal(t, 2, requestCount)
+
+	// A worker invalidating a token that was already replaced keeps the fresh one.
+	c.Invalidate("token1")
+
+	token, err = c.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "token2", token)
+	assert.Equal(t, 2, requestCount)
+}
+
+func TestClientCredentialsExpiry(t *testing.T) {
+	requestCount := 0
+	expiresIn := 10
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+
+		fmt.Fprintf(w, `{"access_token": "token%d", "token_type": "Bearer", "expires_in": %d}`, requestCount, expiresIn)
+	}))
+	defer ts.Close()
+
+	c := NewClientCredentials(&http.Client{}, ts.URL, "id", "secret", nil)
+
+	// Tokens shorter lived than expiryDelta are cached for half of their lifetime.
+	c.Token()
+	token, err := c.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "token1", token)
+	assert.True(t, c.refreshAt.After(time.Now().Add(4*time.Second)))
+	assert.True(t, c.refreshAt.Before(time.Now().Add(6*time.Second)))
+
+	// Tokens expiring within expiryDelta are refreshed.
+	expiresIn = 3600
+	c.refreshAt = time.Now().Add(-time.Second)
+	token, err = c.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "token2", token)
+	assert.True(t, c.refreshAt.After(time.Now().Add(3560*time.Second)))
+	assert.True(t, c.refreshAt.Before(time.Now().Add(3571*time.Second)))
+}
+
+func TestClientCredentialsError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "invalid_client"}`))
+	}))
+	defer ts.Close()
+
+	c := NewClientCredentials(&http.Client{}, ts.URL, "id", "secret", nil)
+
+	_, err := c.Token()
+	assert.Error(t, err)
+}
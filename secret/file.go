@@ -0,0 +1,131 @@
+// Package secret provides values sourced from files that are reloaded whenever the files change, so
+// secrets rotated by e.g. a sidecar are picked up without restarting.
+package secret
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// File holds the contents of a file, without trailing newlines, and keeps it up to date.
+type File struct {
+	path string
+
+	mu        sync.RWMutex
+	value     []byte
+	listeners []func()
+
+	watcher *fsnotify.Watcher
+}
+
+// NewFile reads path and starts watching it for changes. The parent directory is watched rather than the
+// file itself, so that files replaced by a rename or a Kubernetes symlink swap are also picked up.
+func NewFile(path string) (*File, error) {
+	f := &File{path: path}
+
+	value, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	f.value = value
+
+	f.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = f.watcher.Add(filepath.Dir(path)); err != nil {
+		f.watcher.Close()
+		return nil, err
+	}
+
+	go f.watch()
+
+	return f, nil
+}
+
+// Bytes returns the current contents of the file.
+func (f *File) Bytes() []byte {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.value
+}
+
+// String returns the current contents of the file as a string.
+func (f *File) String() string {
+	return string(f.Bytes())
+}
+
+// OnChange registers fn to be called after the contents of the file change.
+func (f *File) OnChange(fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.listeners = append(f.listeners, fn)
+}
+
+// Close stops watching the file.
+func (f *File) Close() error {
+	return f.watcher.Close()
+}
+
+func (f *File) read() ([]byte, error) {
+	value, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(value, "\r\n"), nil
+}
+
+func (f *File) watch() {
+	for {
+		select {
+		case _, ok := <-f.watcher.Events:
+			if !ok {
+				return
+			}
+			f.reload()
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.WithError(err).WithField("file", f.path).Error("Error watching secret file")
+		}
+	}
+}
+
+// reload re-reads the file, keeping the previous contents when it cannot be read or is empty, as happens
+// while it is being rotated.
+func (f *File) reload() {
+	value, err := f.read()
+	if err != nil {
+		log.WithError(err).WithField("file", f.path).Debug("Unable to reload secret file")
+		return
+	}
+
+	if len(value) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	if bytes.Equal(value, f.value) {
+		f.mu.Unlock()
+		return
+	}
+	f.value = value
+	listeners := f.listeners
+	f.mu.Unlock()
+
+	log.WithField("file", f.path).Info("Secret file changed, reloaded")
+
+	for _, fn := range listeners {
+		fn()
+	}
+}
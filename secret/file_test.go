@@ -0,0 +1,66 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForValue(f *File, expected string) bool {
+	for i := 0; i < 100; i++ {
+		if f.String() == expected {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return false
+}
+
+func TestFileReload(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	dir, err := ioutil.TempDir("", "secret")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("first\n"), 0600))
+
+	f, err := NewFile(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	changed := make(chan bool, 10)
+	f.OnChange(func() {
+		changed <- true
+	})
+
+	assert.Equal(t, "first", f.String())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("second"), 0600))
+	assert.True(t, waitForValue(f, "second"))
+
+	// Files replaced through a rename are picked up as well.
+	tmp := filepath.Join(dir, "token.tmp")
+	assert.NoError(t, ioutil.WriteFile(tmp, []byte("third"), 0600))
+	assert.NoError(t, os.Rename(tmp, path))
+	assert.True(t, waitForValue(f, "third"))
+
+	// A missing file keeps the previous value.
+	assert.NoError(t, os.Remove(path))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "third", f.String())
+
+	assert.True(t, len(changed) >= 2)
+}
+
+func TestFileMissing(t *testing.T) {
+	_, err := NewFile(filepath.Join(os.TempDir(), "does-not-exist", "token"))
+	assert.Error(t, err)
+}
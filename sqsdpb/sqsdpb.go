@@ -0,0 +1,5 @@
+// Package sqsdpb contains the gRPC service definition implemented by services that process messages
+// delivered by simple-sqsd.
+package sqsdpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative sqsd.proto
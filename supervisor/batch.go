@@ -0,0 +1,139 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+type batchEntry struct {
+	msg      *sqs.Message
+	delivery Delivery
+}
+
+type batchItem struct {
+	ID         string            `json:"id"`
+	Body       string            `json:"body"`
+	Attributes map[string]string `json:"attributes"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+// batchResponse is the partial batch failure response format used by Lambda event source mappings.
+type batchResponse struct {
+	BatchItemFailures []struct {
+		ItemIdentifier string `json:"itemIdentifier"`
+	} `json:"batchItemFailures"`
+}
+
+// processBatch sends msgs to the service in a single request. Messages listed in the
+// batchItemFailures of the response are left on the queue to be retried, all others are deleted.
+func (s *Supervisor) processBatch(msgs []*sqs.Message) *processed {
+	p := newProcessed()
+
+	entries := make([]batchEntry, 0, len(msgs))
+	delivered := make([]*sqs.Message, 0, len(msgs))
+	pointers := make(map[string]*payloadS3Pointer)
+
+	for _, msg := range msgs {
+		// periodic tasks are requests to their own path rather than messages for the batch
+		if _, ok := periodicTaskFromMessage(msg); ok {
+			s.processMessage(msg, p)
+			continue
+		}
+
+		body, pointer, err := s.messageBody(msg)
+		if err != nil {
+			s.logger.Errorf("Error getting message body: %s", err)
+			continue
+		}
+
+		for _, d := range decodeEnvelopes(s.workerConfig.EnvelopeDecoders, body) {
+			entries = append(entries, batchEntry{msg: msg, delivery: d})
+		}
+
+		delivered = append(delivered, msg)
+		pointers[*msg.MessageId] = pointer
+	}
+
+	if len(entries) == 0 {
+		return p
+	}
+
+	header := http.Header{}
+	body, err := s.encodeBatch(entries, header)
+	if err != nil {
+		s.logger.Errorf("Error encoding batch: %s", err)
+		return p
+	}
+
+	res, err := s.request(body, header)
+	if err != nil {
+		s.logger.Errorf("Error delivering batch: %s", err)
+		return p
+	}
+
+	if !s.handleResponse(res, delivered, p) {
+		return p
+	}
+
+	failures, err := parseBatchItemFailures(res.body)
+	if err != nil {
+		s.logger.Errorf("Error parsing batch response, retrying all messages: %s", err)
+		return p
+	}
+
+	for _, msg := range delivered {
+		if failures[*msg.MessageId] {
+			s.logger.Errorf("Message %s reported as failed", *msg.MessageId)
+			continue
+		}
+
+		p.delete(msg, pointers[*msg.MessageId])
+
+		s.logger.Debugf("Message %s successfully processed", *msg.MessageId)
+	}
+
+	return p
+}
+
+func newBatchItem(msg *sqs.Message, d Delivery) batchItem {
+	item := batchItem{
+		ID:         *msg.MessageId,
+		Body:       d.Body,
+		Attributes: make(map[string]string, len(msg.MessageAttributes)),
+	}
+
+	for k, v := range msg.MessageAttributes {
+		item.Attributes[k] = aws.StringValue(v.StringValue)
+	}
+
+	if len(d.Header) > 0 {
+		item.Headers = make(map[string]string, len(d.Header))
+		for k := range d.Header {
+			item.Headers[k] = d.Header.Get(k)
+		}
+	}
+
+	return item
+}
+
+// parseBatchItemFailures returns the set of failed message ids. An empty response means the whole batch succeeded.
+func parseBatchItemFailures(body []byte) (map[string]bool, error) {
+	failures := make(map[string]bool)
+	if len(body) == 0 {
+		return failures, nil
+	}
+
+	res := &batchResponse{}
+	if err := json.Unmarshal(body, res); err != nil {
+		return nil, err
+	}
+
+	for _, f := range res.BatchItemFailures {
+		failures[f.ItemIdentifier] = true
+	}
+
+	return failures, nil
+}
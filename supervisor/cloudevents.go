@@ -0,0 +1,128 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsDefaultType = "com.amazonaws.sqs.message"
+)
+
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+func (s *Supervisor) newCloudEvent(msg *sqs.Message, d Delivery) (cloudEvent, error) {
+	e := cloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              aws.StringValue(msg.MessageId),
+		Source:          s.cloudEventsSource(),
+		Type:            s.cloudEventsType(msg),
+		Time:            cloudEventsTime(msg),
+		DataContentType: s.workerConfig.HTTPContentType,
+	}
+
+	// JSON bodies are embedded as is, everything else is carried as a JSON string.
+	if json.Valid([]byte(d.Body)) {
+		e.Data = json.RawMessage(d.Body)
+	} else {
+		data, err := json.Marshal(d.Body)
+		if err != nil {
+			return e, err
+		}
+		e.Data = data
+	}
+
+	return e, nil
+}
+
+// encodeCloudEvent encodes a delivery as a CloudEvent in structured content mode.
+func (s *Supervisor) encodeCloudEvent(msg *sqs.Message, d Delivery) (string, error) {
+	e, err := s.newCloudEvent(msg, d)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// encodeCloudEventsBatch encodes entries as a JSON array of CloudEvents in batched content mode.
+func (s *Supervisor) encodeCloudEventsBatch(entries []batchEntry) (string, error) {
+	events := make([]cloudEvent, 0, len(entries))
+	for _, entry := range entries {
+		e, err := s.newCloudEvent(entry.msg, entry.delivery)
+		if err != nil {
+			return "", err
+		}
+
+		events = append(events, e)
+	}
+
+	body, err := json.Marshal(events)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// addCloudEventsHeaders adds the context attributes of a CloudEvent in binary content mode to header.
+func (s *Supervisor) addCloudEventsHeaders(msg *sqs.Message, header http.Header) {
+	header.Set("Ce-Specversion", cloudEventsSpecVersion)
+	header.Set("Ce-Id", aws.StringValue(msg.MessageId))
+	header.Set("Ce-Source", s.cloudEventsSource())
+	header.Set("Ce-Type", s.cloudEventsType(msg))
+
+	if t := cloudEventsTime(msg); len(t) > 0 {
+		header.Set("Ce-Time", t)
+	}
+}
+
+func (s *Supervisor) cloudEventsSource() string {
+	if len(s.queueARN) > 0 {
+		return s.queueARN
+	}
+
+	return s.workerConfig.QueueURL
+}
+
+// cloudEventsType returns the value of the configured type attribute of msg, falling back to the default type.
+func (s *Supervisor) cloudEventsType(msg *sqs.Message) string {
+	if attr, ok := msg.MessageAttributes[s.workerConfig.CloudEventsTypeAttribute]; ok && attr.StringValue != nil {
+		return *attr.StringValue
+	}
+
+	if len(s.workerConfig.CloudEventsDefaultType) > 0 {
+		return s.workerConfig.CloudEventsDefaultType
+	}
+
+	return cloudEventsDefaultType
+}
+
+// cloudEventsTime returns the time msg was sent to the queue, if known.
+func cloudEventsTime(msg *sqs.Message) string {
+	sent, err := strconv.ParseInt(aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]), 10, 64)
+	if err != nil {
+		return ""
+	}
+
+	return time.Unix(0, sent*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,32 @@
+package supervisor
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// deadLetter moves msgs to the configured dead-letter queue. Messages are only deleted from the queue once
+// they have been sent, and are otherwise left to be retried.
+func (s *Supervisor) deadLetter(msgs []*sqs.Message, p *processed) {
+	if len(s.workerConfig.DeadLetterQueueURL) == 0 {
+		s.logger.Error("Dead-letter requested but no dead-letter queue is configured")
+		return
+	}
+
+	for _, msg := range msgs {
+		_, err := s.sqs.SendMessage(&sqs.SendMessageInput{
+			QueueUrl:          aws.String(s.workerConfig.DeadLetterQueueURL),
+			MessageBody:       msg.Body,
+			MessageAttributes: msg.MessageAttributes,
+		})
+		if err != nil {
+			s.logger.Errorf("Error while sending message %s to the dead-letter queue: %s", *msg.MessageId, err)
+			continue
+		}
+
+		// The offloaded payload, if any, is still referenced by the dead-lettered message and is kept.
+		p.delete(msg, nil)
+
+		s.logger.Infof("Message %s moved to the dead-letter queue", *msg.MessageId)
+	}
+}
@@ -0,0 +1,45 @@
+package supervisor
+
+import "net/http"
+
+// Delivery is a single request body, along with any headers derived from its envelope, sent for a message.
+type Delivery struct {
+	Body   string
+	Header http.Header
+}
+
+// EnvelopeDecoder unwraps message bodies that were produced by another AWS service.
+// Decode returns false when body is not an envelope the decoder understands.
+type EnvelopeDecoder interface {
+	Decode(body string) ([]Delivery, bool)
+}
+
+// decodeEnvelopes recursively applies decoders to body so that nested envelopes (e.g. S3 notifications
+// published through SNS) are fully unwrapped. Headers from outer envelopes are carried to inner deliveries.
+func decodeEnvelopes(decoders []EnvelopeDecoder, body string) []Delivery {
+	return decodeDelivery(decoders, Delivery{Body: body, Header: http.Header{}})
+}
+
+func decodeDelivery(decoders []EnvelopeDecoder, d Delivery) []Delivery {
+	for _, decoder := range decoders {
+		inner, ok := decoder.Decode(d.Body)
+		if !ok || len(inner) == 0 {
+			continue
+		}
+
+		deliveries := make([]Delivery, 0, len(inner))
+		for _, i := range inner {
+			header := d.Header.Clone()
+			for k, v := range i.Header {
+				header[k] = v
+			}
+			i.Header = header
+
+			deliveries = append(deliveries, decodeDelivery(decoders, i)...)
+		}
+
+		return deliveries
+	}
+
+	return []Delivery{d}
+}
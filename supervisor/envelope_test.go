@@ -0,0 +1,61 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const s3EventBody = `{"Records": [
+	{"eventSource": "aws:s3", "eventName": "ObjectCreated:Put", "s3": {"bucket": {"name": "bucket"}, "object": {"key": "a.txt"}}},
+	{"eventSource": "aws:s3", "eventName": "ObjectRemoved:Delete", "s3": {"bucket": {"name": "bucket"}, "object": {"key": "b.txt"}}}
+]}`
+
+func TestDecodeEnvelopesNoMatch(t *testing.T) {
+	decoders := []EnvelopeDecoder{SNSEnvelopeDecoder{}, EventBridgeEnvelopeDecoder{}, S3EventEnvelopeDecoder{}}
+
+	for _, body := range []string{"plain text", `{"foo":"bar"}`, `{"Records": []}`, `{"Records": [{"eventSource": "aws:sqs"}]}`} {
+		deliveries := decodeEnvelopes(decoders, body)
+
+		assert.Len(t, deliveries, 1)
+		assert.Equal(t, body, deliveries[0].Body)
+		assert.Empty(t, deliveries[0].Header)
+	}
+}
+
+func TestDecodeEnvelopesEventBridge(t *testing.T) {
+	body := `{"version": "0", "id": "e1", "detail-type": "Order Placed", "source": "com.example.orders", "detail": {"orderId": 1}}`
+
+	deliveries := decodeEnvelopes([]EnvelopeDecoder{EventBridgeEnvelopeDecoder{}}, body)
+
+	assert.Len(t, deliveries, 1)
+	assert.JSONEq(t, `{"orderId": 1}`, deliveries[0].Body)
+	assert.Equal(t, "e1", deliveries[0].Header.Get("X-Aws-Eventbridge-Id"))
+	assert.Equal(t, "Order Placed", deliveries[0].Header.Get("X-Aws-Eventbridge-Detail-Type"))
+	assert.Equal(t, "com.example.orders", deliveries[0].Header.Get("X-Aws-Eventbridge-Source"))
+}
+
+func TestDecodeEnvelopesS3Event(t *testing.T) {
+	deliveries := decodeEnvelopes([]EnvelopeDecoder{S3EventEnvelopeDecoder{}}, s3EventBody)
+
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, "ObjectCreated:Put", deliveries[0].Header.Get("X-Aws-S3-Event-Name"))
+	assert.Equal(t, "a.txt", deliveries[0].Header.Get("X-Aws-S3-Key"))
+	assert.Equal(t, "ObjectRemoved:Delete", deliveries[1].Header.Get("X-Aws-S3-Event-Name"))
+	assert.Equal(t, "b.txt", deliveries[1].Header.Get("X-Aws-S3-Key"))
+	assert.Equal(t, "bucket", deliveries[1].Header.Get("X-Aws-S3-Bucket"))
+}
+
+func TestDecodeEnvelopesNested(t *testing.T) {
+	message, _ := json.Marshal(s3EventBody)
+	body := `{"Type": "Notification", "TopicArn": "arn:aws:sns:us-east-1:123456789012:topic", "Message": ` + string(message) + `}`
+
+	deliveries := decodeEnvelopes([]EnvelopeDecoder{SNSEnvelopeDecoder{}, S3EventEnvelopeDecoder{}}, body)
+
+	assert.Len(t, deliveries, 2)
+	for _, d := range deliveries {
+		assert.Equal(t, "arn:aws:sns:us-east-1:123456789012:topic", d.Header.Get("X-Aws-Sns-Topic-Arn"))
+		assert.Equal(t, "bucket", d.Header.Get("X-Aws-S3-Bucket"))
+	}
+}
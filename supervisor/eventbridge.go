@@ -0,0 +1,35 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// EventBridgeEnvelopeDecoder unwraps EventBridge events, delivering only the event detail with the
+// detail-type, source and event id as headers.
+type EventBridgeEnvelopeDecoder struct{}
+
+type eventBridgeEvent struct {
+	ID         string          `json:"id"`
+	DetailType *string         `json:"detail-type"`
+	Source     *string         `json:"source"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+func (EventBridgeEnvelopeDecoder) Decode(body string) ([]Delivery, bool) {
+	e := &eventBridgeEvent{}
+	if err := json.Unmarshal([]byte(body), e); err != nil {
+		return nil, false
+	}
+
+	if e.DetailType == nil || e.Source == nil || len(e.Detail) == 0 {
+		return nil, false
+	}
+
+	header := http.Header{}
+	header.Set("X-Aws-Eventbridge-Id", e.ID)
+	header.Set("X-Aws-Eventbridge-Detail-Type", *e.DetailType)
+	header.Set("X-Aws-Eventbridge-Source", *e.Source)
+
+	return []Delivery{{Body: string(e.Detail), Header: header}}, true
+}
@@ -0,0 +1,85 @@
+package supervisor
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// execTarget runs a command for each request, with the body on stdin and the headers as environment
+// variables. A zero exit code is treated as a 200 response and anything else as a 500 response.
+type execTarget struct {
+	logger  *log.Entry
+	command []string
+	timeout time.Duration
+}
+
+func (t *execTarget) send(body string, header http.Header) (*response, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(t.command[0], t.command[1:]...)
+	cmd.Stdin = strings.NewReader(body)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), headerToEnv(header)...)
+	// Run the command in its own process group, so that processes it starts (e.g. under sh -c) are
+	// killed along with it when it times out, rather than holding its output open.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Error while running command: %s", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var timeout <-chan time.Time
+	if t.timeout > 0 {
+		timer := time.NewTimer(t.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case err = <-done:
+	case <-timeout:
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return nil, fmt.Errorf("Command timed out after %s: %s", t.timeout, stderr.String())
+	}
+
+	if _, ok := err.(*exec.ExitError); ok {
+		t.logger.Errorf("Command failed with %s: %s", err, stderr.String())
+		return &response{statusCode: http.StatusInternalServerError, header: http.Header{}, body: stdout.Bytes()}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error while running command: %s", err)
+	}
+
+	if stderr.Len() > 0 {
+		t.logger.Debugf("Command stderr: %s", stderr.String())
+	}
+
+	return &response{statusCode: http.StatusOK, header: http.Header{}, body: stdout.Bytes()}, nil
+}
+
+// headerToEnv converts header to environment variables, upper casing names and replacing dashes with
+// underscores (e.g. X-Aws-Sqsd-Msgid becomes X_AWS_SQSD_MSGID).
+func headerToEnv(header http.Header) []string {
+	env := make([]string, 0, len(header))
+	for k := range header {
+		name := strings.ToUpper(strings.Replace(k, "-", "_", -1))
+		env = append(env, name+"="+header.Get(k))
+	}
+
+	return env
+}
@@ -0,0 +1,308 @@
+package supervisor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FastCGI record types and roles, see https://fastcgi-archives.github.io/FastCGI_Specification.html.
+const (
+	fcgiVersion = 1
+
+	fcgiBeginRequest = 1
+	fcgiEndRequest   = 3
+	fcgiParams       = 4
+	fcgiStdin        = 5
+	fcgiStdout       = 6
+	fcgiStderr       = 7
+
+	fcgiResponder = 1
+
+	fcgiRequestComplete = 0
+	fcgiCantMpxConn     = 1
+	fcgiOverloaded      = 2
+	fcgiUnknownRole     = 3
+
+	fcgiRequestID     = 1
+	fcgiMaxContentLen = 65535
+)
+
+// fcgiProtocolStatuses describes the protocol statuses a request can end with other than fcgiRequestComplete.
+var fcgiProtocolStatuses = map[uint8]string{
+	fcgiCantMpxConn: "cannot multiplex connections",
+	fcgiOverloaded:  "overloaded",
+	fcgiUnknownRole: "unknown role",
+}
+
+type fcgiHeader struct {
+	Version       uint8
+	Type          uint8
+	RequestID     uint16
+	ContentLength uint16
+	PaddingLength uint8
+	Reserved      uint8
+}
+
+type fcgiEndRequestBody struct {
+	AppStatus      uint32
+	ProtocolStatus uint8
+	Reserved       [3]uint8
+}
+
+// fcgiTarget sends requests directly to a FastCGI responder such as php-fpm. The Status header of the
+// response is interpreted the same way as an HTTP status code.
+type fcgiTarget struct {
+	network        string
+	address        string
+	scriptFilename string
+	requestURI     string
+	timeout        time.Duration
+}
+
+func newFCGITarget(address string, scriptFilename string, requestURI string, timeout time.Duration) *fcgiTarget {
+	t := &fcgiTarget{
+		network:        "tcp",
+		address:        strings.TrimPrefix(address, "tcp://"),
+		scriptFilename: scriptFilename,
+		requestURI:     requestURI,
+		timeout:        timeout,
+	}
+
+	if strings.HasPrefix(address, "unix://") {
+		t.network = "unix"
+		t.address = strings.TrimPrefix(address, "unix://")
+	}
+
+	if len(t.requestURI) == 0 {
+		t.requestURI = "/"
+	}
+
+	return t
+}
+
+func (t *fcgiTarget) send(body string, header http.Header) (*response, error) {
+	conn, err := net.DialTimeout(t.network, t.address, t.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("Error while connecting to FastCGI server: %s", err)
+	}
+	defer conn.Close()
+
+	if t.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(t.timeout))
+	}
+
+	w := bufio.NewWriter(conn)
+
+	beginRequest := []byte{0, fcgiResponder, 0, 0, 0, 0, 0, 0}
+	if err := writeFCGIRecord(w, fcgiBeginRequest, beginRequest); err != nil {
+		return nil, err
+	}
+
+	if err := writeFCGIStream(w, fcgiParams, encodeFCGIParams(t.params(body, header))); err != nil {
+		return nil, err
+	}
+
+	if err := writeFCGIStream(w, fcgiStdin, []byte(body)); err != nil {
+		return nil, err
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("Error while writing FastCGI request: %s", err)
+	}
+
+	stdout, stderr, err := readFCGIResponse(conn)
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading FastCGI response: %s", err)
+	}
+
+	res, err := parseCGIResponse(stdout)
+	if err != nil {
+		return nil, fmt.Errorf("Error while parsing FastCGI response: %s: %s", err, stderr)
+	}
+
+	return res, nil
+}
+
+func (t *fcgiTarget) params(body string, header http.Header) map[string]string {
+	params := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_PROTOCOL":   "HTTP/1.1",
+		"SERVER_SOFTWARE":   "simple-sqsd",
+		"REQUEST_METHOD":    "POST",
+		"REQUEST_URI":       t.requestURI,
+		"SCRIPT_FILENAME":   t.scriptFilename,
+		"SCRIPT_NAME":       t.requestURI,
+		"QUERY_STRING":      "",
+		"CONTENT_LENGTH":    strconv.Itoa(len(body)),
+		"CONTENT_TYPE":      header.Get("Content-Type"),
+	}
+
+	if i := strings.Index(t.requestURI, "?"); i >= 0 {
+		params["SCRIPT_NAME"] = t.requestURI[:i]
+		params["QUERY_STRING"] = t.requestURI[i+1:]
+	}
+
+	for k := range header {
+		if k == "Content-Type" || k == "Content-Length" {
+			continue
+		}
+
+		params["HTTP_"+strings.ToUpper(strings.Replace(k, "-", "_", -1))] = header.Get(k)
+	}
+
+	return params
+}
+
+func writeFCGIRecord(w io.Writer, recType uint8, content []byte) error {
+	padding := uint8(-len(content) & 7)
+
+	h := fcgiHeader{
+		Version:       fcgiVersion,
+		Type:          recType,
+		RequestID:     fcgiRequestID,
+		ContentLength: uint16(len(content)),
+		PaddingLength: padding,
+	}
+
+	if err := binary.Write(w, binary.BigEndian, h); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+
+	_, err := w.Write(make([]byte, padding))
+	return err
+}
+
+// writeFCGIStream writes content as a stream of records, terminated by an empty record.
+func writeFCGIStream(w io.Writer, recType uint8, content []byte) error {
+	for len(content) > 0 {
+		n := len(content)
+		if n > fcgiMaxContentLen {
+			n = fcgiMaxContentLen
+		}
+
+		if err := writeFCGIRecord(w, recType, content[:n]); err != nil {
+			return err
+		}
+
+		content = content[n:]
+	}
+
+	return writeFCGIRecord(w, recType, nil)
+}
+
+func encodeFCGIParams(params map[string]string) []byte {
+	var buf bytes.Buffer
+
+	for k, v := range params {
+		writeFCGIParamLength(&buf, len(k))
+		writeFCGIParamLength(&buf, len(v))
+		buf.WriteString(k)
+		buf.WriteString(v)
+	}
+
+	return buf.Bytes()
+}
+
+func writeFCGIParamLength(buf *bytes.Buffer, n int) {
+	if n < 128 {
+		buf.WriteByte(byte(n))
+		return
+	}
+
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(n)|1<<31)
+	buf.Write(b[:])
+}
+
+// readFCGIResponse reads records until the end of the request, returning the contents of stdout and stderr.
+// Requests the responder rejected or that ended with a non-zero application status are errors.
+func readFCGIResponse(r io.Reader) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	for {
+		h := fcgiHeader{}
+		if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+			return nil, nil, err
+		}
+
+		content := make([]byte, int(h.ContentLength)+int(h.PaddingLength))
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, nil, err
+		}
+		content = content[:h.ContentLength]
+
+		switch h.Type {
+		case fcgiStdout:
+			stdout.Write(content)
+		case fcgiStderr:
+			stderr.Write(content)
+		case fcgiEndRequest:
+			body := fcgiEndRequestBody{}
+			if err := binary.Read(bytes.NewReader(content), binary.BigEndian, &body); err != nil {
+				return nil, nil, fmt.Errorf("invalid end request record: %s", err)
+			}
+
+			if body.ProtocolStatus != fcgiRequestComplete {
+				status, ok := fcgiProtocolStatuses[body.ProtocolStatus]
+				if !ok {
+					status = fmt.Sprintf("protocol status %d", body.ProtocolStatus)
+				}
+				return nil, nil, fmt.Errorf("request rejected: %s", status)
+			}
+
+			if body.AppStatus != 0 {
+				return nil, nil, fmt.Errorf("request ended with application status %d: %s", body.AppStatus, stderr.Bytes())
+			}
+
+			return stdout.Bytes(), stderr.Bytes(), nil
+		}
+	}
+}
+
+// parseCGIResponse parses the headers and body written by a CGI responder. Responses without a Status
+// header are successful, but responses without any headers are invalid.
+func parseCGIResponse(stdout []byte) (*response, error) {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(stdout)))
+
+	mimeHeader, err := r.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if len(mimeHeader) == 0 {
+		return nil, errors.New("response has no CGI headers")
+	}
+
+	header := http.Header(mimeHeader)
+	statusCode := http.StatusOK
+
+	if status := header.Get("Status"); len(status) > 0 {
+		code := strings.SplitN(status, " ", 2)[0]
+		statusCode, err = strconv.Atoi(code)
+		if err != nil {
+			return nil, errors.New("invalid Status header: " + status)
+		}
+	}
+
+	body, err := ioutil.ReadAll(r.R)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response{statusCode: statusCode, header: header, body: body}, nil
+}
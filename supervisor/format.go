@@ -0,0 +1,65 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// PayloadFormat controls how messages are encoded in the body of requests made to the service.
+type PayloadFormat string
+
+const (
+	// PayloadFormatRaw sends the message body as is, or a JSON array of messages in batch delivery mode.
+	PayloadFormatRaw PayloadFormat = "raw"
+	// PayloadFormatLambda sends the SQS event payload Lambda invokes functions with.
+	PayloadFormatLambda PayloadFormat = "lambda"
+	// PayloadFormatCloudEventsBinary sends the message body as is, with CloudEvents context attributes as headers.
+	PayloadFormatCloudEventsBinary PayloadFormat = "cloudevents-binary"
+	// PayloadFormatCloudEventsStructured sends a CloudEvents JSON envelope holding the message body as data.
+	PayloadFormatCloudEventsStructured PayloadFormat = "cloudevents-structured"
+)
+
+// encode encodes a single delivery in the configured payload format.
+func (s *Supervisor) encode(msg *sqs.Message, d Delivery, header http.Header) (string, error) {
+	switch s.workerConfig.PayloadFormat {
+	case PayloadFormatLambda:
+		header.Set("Content-Type", "application/json")
+		return s.encodeLambdaEvent([]batchEntry{{msg: msg, delivery: d}})
+	case PayloadFormatCloudEventsBinary:
+		s.addCloudEventsHeaders(msg, header)
+	case PayloadFormatCloudEventsStructured:
+		header.Set("Content-Type", "application/cloudevents+json")
+		return s.encodeCloudEvent(msg, d)
+	}
+
+	return d.Body, nil
+}
+
+// encodeBatch encodes entries for batch delivery in the configured payload format.
+// Both CloudEvents formats use the batched content mode, as binary mode cannot hold more than one event.
+func (s *Supervisor) encodeBatch(entries []batchEntry, header http.Header) (string, error) {
+	switch s.workerConfig.PayloadFormat {
+	case PayloadFormatLambda:
+		header.Set("Content-Type", "application/json")
+		return s.encodeLambdaEvent(entries)
+	case PayloadFormatCloudEventsBinary, PayloadFormatCloudEventsStructured:
+		header.Set("Content-Type", "application/cloudevents-batch+json")
+		return s.encodeCloudEventsBatch(entries)
+	}
+
+	header.Set("Content-Type", "application/json")
+
+	items := make([]batchItem, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, newBatchItem(e.msg, e.delivery))
+	}
+
+	body, err := json.Marshal(items)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
@@ -0,0 +1,57 @@
+package supervisor
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fterrag/simple-sqsd/sqsdpb"
+)
+
+// grpcTarget calls the Deliver method of a sqsdpb.WorkerClient for each request. Results are mapped to
+// the HTTP status codes the supervisor already handles.
+type grpcTarget struct {
+	client  sqsdpb.WorkerClient
+	timeout time.Duration
+}
+
+func (t *grpcTarget) send(body string, header http.Header) (*response, error) {
+	ctx := context.Background()
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	msg := &sqsdpb.Message{
+		Id:      header.Get("X-Aws-Sqsd-Msgid"),
+		Body:    []byte(body),
+		Headers: make(map[string]string, len(header)),
+	}
+
+	for k := range header {
+		msg.Headers[k] = header.Get(k)
+	}
+
+	result, err := t.client.Deliver(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &response{statusCode: http.StatusInternalServerError, header: http.Header{}}
+
+	switch result.Action {
+	case sqsdpb.Result_ACTION_DELETE:
+		res.statusCode = http.StatusOK
+	case sqsdpb.Result_ACTION_RETRY:
+		if result.RetryDelaySeconds > 0 {
+			res.statusCode = http.StatusTooManyRequests
+			res.header.Set("Retry-After", strconv.FormatInt(result.RetryDelaySeconds, 10))
+		}
+	case sqsdpb.Result_ACTION_DEAD_LETTER:
+		res.deadLetter = true
+	}
+
+	return res, nil
+}
@@ -0,0 +1,82 @@
+package supervisor
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// lambdaEvent is the SQS event payload Lambda event source mappings invoke functions with.
+type lambdaEvent struct {
+	Records []lambdaRecord `json:"Records"`
+}
+
+type lambdaRecord struct {
+	MessageID         string                            `json:"messageId"`
+	ReceiptHandle     string                            `json:"receiptHandle"`
+	Body              string                            `json:"body"`
+	Attributes        map[string]string                 `json:"attributes"`
+	MessageAttributes map[string]lambdaMessageAttribute `json:"messageAttributes"`
+	MD5OfBody         string                            `json:"md5OfBody"`
+	EventSource       string                            `json:"eventSource"`
+	EventSourceARN    string                            `json:"eventSourceARN"`
+	AWSRegion         string                            `json:"awsRegion"`
+}
+
+type lambdaMessageAttribute struct {
+	StringValue      *string  `json:"stringValue,omitempty"`
+	BinaryValue      []byte   `json:"binaryValue,omitempty"`
+	StringListValues []string `json:"stringListValues"`
+	BinaryListValues [][]byte `json:"binaryListValues"`
+	DataType         string   `json:"dataType"`
+}
+
+func (s *Supervisor) newLambdaRecord(msg *sqs.Message, d Delivery) lambdaRecord {
+	sum := md5.Sum([]byte(d.Body))
+
+	record := lambdaRecord{
+		MessageID:         aws.StringValue(msg.MessageId),
+		ReceiptHandle:     aws.StringValue(msg.ReceiptHandle),
+		Body:              d.Body,
+		Attributes:        aws.StringValueMap(msg.Attributes),
+		MessageAttributes: make(map[string]lambdaMessageAttribute, len(msg.MessageAttributes)),
+		MD5OfBody:         hex.EncodeToString(sum[:]),
+		EventSource:       "aws:sqs",
+		EventSourceARN:    s.queueARN,
+		AWSRegion:         s.workerConfig.QueueRegion,
+	}
+
+	for k, v := range msg.MessageAttributes {
+		attr := lambdaMessageAttribute{
+			StringValue:      v.StringValue,
+			BinaryValue:      v.BinaryValue,
+			StringListValues: aws.StringValueSlice(v.StringListValues),
+			BinaryListValues: v.BinaryListValues,
+			DataType:         aws.StringValue(v.DataType),
+		}
+		if attr.BinaryListValues == nil {
+			attr.BinaryListValues = [][]byte{}
+		}
+
+		record.MessageAttributes[k] = attr
+	}
+
+	return record
+}
+
+func (s *Supervisor) encodeLambdaEvent(entries []batchEntry) (string, error) {
+	event := lambdaEvent{Records: make([]lambdaRecord, 0, len(entries))}
+	for _, e := range entries {
+		event.Records = append(event.Records, s.newLambdaRecord(e.msg, e.delivery))
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
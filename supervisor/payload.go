@@ -0,0 +1,92 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// payloadS3PointerClass is the class name the SQS Extended Client Library uses to mark message bodies
+// that were offloaded to S3.
+const payloadS3PointerClass = "software.amazon.payloadoffloading.PayloadS3Pointer"
+
+type payloadS3Pointer struct {
+	Bucket string `json:"s3BucketName"`
+	Key    string `json:"s3Key"`
+}
+
+// parsePayloadS3Pointer returns the S3 pointer contained in body, if any. Pointers are serialized as
+// a two element JSON array of the class name followed by the bucket and key.
+func parsePayloadS3Pointer(body string) (*payloadS3Pointer, bool) {
+	var parts []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &parts); err != nil || len(parts) != 2 {
+		return nil, false
+	}
+
+	var class string
+	if err := json.Unmarshal(parts[0], &class); err != nil || class != payloadS3PointerClass {
+		return nil, false
+	}
+
+	pointer := &payloadS3Pointer{}
+	if err := json.Unmarshal(parts[1], pointer); err != nil || len(pointer.Bucket) == 0 || len(pointer.Key) == 0 {
+		return nil, false
+	}
+
+	return pointer, true
+}
+
+// messageBody returns the body to deliver for msg, fetching it from S3 when the message only holds
+// a pointer to an offloaded payload. The pointer is returned so the object can be deleted later.
+func (s *Supervisor) messageBody(msg *sqs.Message) (string, *payloadS3Pointer, error) {
+	if s.workerConfig.PayloadS3 == nil {
+		return *msg.Body, nil, nil
+	}
+
+	pointer, ok := parsePayloadS3Pointer(*msg.Body)
+	if !ok {
+		return *msg.Body, nil, nil
+	}
+
+	output, err := s.workerConfig.PayloadS3.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(pointer.Bucket),
+		Key:    aws.String(pointer.Key),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("Error while getting payload s3://%s/%s: %s", pointer.Bucket, pointer.Key, err)
+	}
+	defer output.Body.Close()
+
+	body, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("Error while reading payload s3://%s/%s: %s", pointer.Bucket, pointer.Key, err)
+	}
+
+	return string(body), pointer, nil
+}
+
+// deletePayloads removes the offloaded payloads of messages that were successfully deleted from the queue.
+func (s *Supervisor) deletePayloads(pointers map[string]*payloadS3Pointer, output *sqs.DeleteMessageBatchOutput) {
+	if !s.workerConfig.DeletePayloadsFromS3 || output == nil {
+		return
+	}
+
+	for _, entry := range output.Successful {
+		pointer, ok := pointers[*entry.Id]
+		if !ok {
+			continue
+		}
+
+		_, err := s.workerConfig.PayloadS3.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(pointer.Bucket),
+			Key:    aws.String(pointer.Key),
+		})
+		if err != nil {
+			s.logger.Errorf("Error while deleting payload s3://%s/%s: %s", pointer.Bucket, pointer.Key, err)
+		}
+	}
+}
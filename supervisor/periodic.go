@@ -0,0 +1,72 @@
+package supervisor
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// Message attributes and body of periodic task messages, as enqueued by Elastic Beanstalk's sqsd and by
+// the cron worker when dispatching through the queue.
+const (
+	PeriodicTaskNameAttribute        = "beanstalk.sqsd.task_name"
+	PeriodicTaskPathAttribute        = "beanstalk.sqsd.path"
+	PeriodicTaskScheduledAtAttribute = "beanstalk.sqsd.scheduled_time"
+
+	PeriodicTaskBody = "elasticbeanstalk scheduled job"
+)
+
+type periodicTask struct {
+	name        string
+	path        string
+	scheduledAt string
+}
+
+func periodicTaskFromMessage(msg *sqs.Message) (periodicTask, bool) {
+	name, ok := msg.MessageAttributes[PeriodicTaskNameAttribute]
+	if !ok {
+		return periodicTask{}, false
+	}
+
+	task := periodicTask{name: aws.StringValue(name.StringValue)}
+
+	if path, ok := msg.MessageAttributes[PeriodicTaskPathAttribute]; ok {
+		task.path = aws.StringValue(path.StringValue)
+	}
+
+	if scheduledAt, ok := msg.MessageAttributes[PeriodicTaskScheduledAtAttribute]; ok {
+		task.scheduledAt = aws.StringValue(scheduledAt.StringValue)
+	}
+
+	return task, true
+}
+
+// deliverPeriodicTask sends a periodic task as-is with the headers set by Elastic Beanstalk. When
+// delivering over HTTP, the request is made to the path of the task rather than HTTPURL.
+func (s *Supervisor) deliverPeriodicTask(msg *sqs.Message, task periodicTask, body string) (*response, error) {
+	header := http.Header{}
+	header.Add("X-Aws-Sqsd-Msgid", *msg.MessageId)
+	header.Add("X-Aws-Sqsd-Taskname", task.name)
+
+	if len(task.scheduledAt) > 0 {
+		header.Add("X-Aws-Sqsd-Scheduled-At", task.scheduledAt)
+	}
+
+	s.addMessageAttributesToHeader(msg.MessageAttributes, header)
+
+	if _, ok := s.target.(*httpTarget); !ok || len(task.path) == 0 {
+		return s.request(body, header)
+	}
+
+	baseURL := s.workerConfig.PeriodicTasksURL
+	if len(baseURL) == 0 {
+		baseURL = s.workerConfig.HTTPURL
+	}
+
+	taskURL := baseURL + task.path
+	t := &httpTarget{client: s.httpClient, url: taskURL}
+
+	return s.requestTarget(t, fmt.Sprintf("POST %s\n", taskURL), body, header)
+}
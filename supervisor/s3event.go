@@ -0,0 +1,55 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// S3EventEnvelopeDecoder fans S3 event notifications out into one delivery per record.
+// Each record is delivered on its own with the event name, bucket and object key as headers.
+type S3EventEnvelopeDecoder struct{}
+
+type s3EventNotification struct {
+	Records []json.RawMessage `json:"Records"`
+}
+
+type s3EventRecord struct {
+	EventSource string `json:"eventSource"`
+	EventName   string `json:"eventName"`
+	S3          struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key string `json:"key"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+func (S3EventEnvelopeDecoder) Decode(body string) ([]Delivery, bool) {
+	n := &s3EventNotification{}
+	if err := json.Unmarshal([]byte(body), n); err != nil {
+		return nil, false
+	}
+
+	if len(n.Records) == 0 {
+		return nil, false
+	}
+
+	deliveries := make([]Delivery, 0, len(n.Records))
+	for _, raw := range n.Records {
+		r := &s3EventRecord{}
+		if err := json.Unmarshal(raw, r); err != nil || r.EventSource != "aws:s3" {
+			return nil, false
+		}
+
+		header := http.Header{}
+		header.Set("X-Aws-S3-Event-Name", r.EventName)
+		header.Set("X-Aws-S3-Bucket", r.S3.Bucket.Name)
+		header.Set("X-Aws-S3-Key", r.S3.Object.Key)
+
+		deliveries = append(deliveries, Delivery{Body: string(raw), Header: header})
+	}
+
+	return deliveries, true
+}
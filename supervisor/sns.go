@@ -0,0 +1,48 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// SNSEnvelopeDecoder unwraps SNS notifications delivered to a queue without raw message delivery.
+// The inner Message is delivered with the TopicArn, Subject and SNS message attributes as headers.
+type SNSEnvelopeDecoder struct{}
+
+type snsNotification struct {
+	Type              string                         `json:"Type"`
+	MessageID         string                         `json:"MessageId"`
+	TopicArn          string                         `json:"TopicArn"`
+	Subject           string                         `json:"Subject"`
+	Message           *string                        `json:"Message"`
+	MessageAttributes map[string]snsMessageAttribute `json:"MessageAttributes"`
+}
+
+type snsMessageAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+func (SNSEnvelopeDecoder) Decode(body string) ([]Delivery, bool) {
+	n := &snsNotification{}
+	if err := json.Unmarshal([]byte(body), n); err != nil {
+		return nil, false
+	}
+
+	if n.Type != "Notification" || len(n.TopicArn) == 0 || n.Message == nil {
+		return nil, false
+	}
+
+	header := http.Header{}
+	header.Set("X-Aws-Sns-Topic-Arn", n.TopicArn)
+
+	if len(n.Subject) > 0 {
+		header.Set("X-Aws-Sns-Subject", n.Subject)
+	}
+
+	for k, v := range n.MessageAttributes {
+		header.Add("X-Aws-Sns-Attr-"+k, v.Value)
+	}
+
+	return []Delivery{{Body: *n.Message, Header: header}}, true
+}
@@ -1,20 +1,24 @@
 package supervisor
 
 import (
-	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/fterrag/simple-sqsd/secret"
+	"github.com/fterrag/simple-sqsd/sqsdpb"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -23,9 +27,11 @@ type Supervisor struct {
 
 	logger        *log.Entry
 	sqs           sqsiface.SQSAPI
+	target        target
 	httpClient    httpClient
 	workerConfig  WorkerConfig
 	hmacSignature string
+	queueARN      string
 
 	startOnce sync.Once
 	wg        sync.WaitGroup
@@ -34,6 +40,7 @@ type Supervisor struct {
 }
 
 type WorkerConfig struct {
+	QueueRegion      string
 	QueueURL         string
 	QueueMaxMessages int
 	QueueWaitTime    int
@@ -41,13 +48,66 @@ type WorkerConfig struct {
 	HTTPURL         string
 	HTTPContentType string
 
-    HTTPAUTHORIZATIONHeader string
+	HTTPAUTHORIZATIONHeader     string
 	HTTPAUTHORIZATIONHeaderName string
 
 	HTTPHMACHeader string
 	HMACSecretKey  []byte
 
+	// HMACSecretKeyFile and HTTPAUTHORIZATIONHeaderFile take precedence over HMACSecretKey and
+	// HTTPAUTHORIZATIONHeader when set, and are reloaded whenever their files change.
+	HMACSecretKeyFile           *secret.File
+	HTTPAUTHORIZATIONHeaderFile *secret.File
+
+	// TokenSource, when set, supplies bearer tokens for the authorization header. A request rejected with a
+	// 401 is retried once with a freshly fetched token.
+	TokenSource TokenSource
+
 	UserAgent string
+
+	EnvelopeDecoders []EnvelopeDecoder
+
+	// PayloadS3 enables fetching bodies offloaded to S3 by the SQS Extended Client Library.
+	PayloadS3            s3iface.S3API
+	DeletePayloadsFromS3 bool
+
+	// BatchDelivery sends all received messages to the service in a single request.
+	BatchDelivery bool
+	PayloadFormat PayloadFormat
+
+	// CloudEventsTypeAttribute names the message attribute holding the CloudEvents type of a message.
+	CloudEventsTypeAttribute string
+	CloudEventsDefaultType   string
+
+	// ExecCommand, when set, is run for each message instead of making an HTTP request to HTTPURL.
+	ExecCommand []string
+	ExecTimeout time.Duration
+
+	// FastCGIAddress, when set, sends each message directly to a FastCGI server (e.g. php-fpm) listening at
+	// tcp://host:port or unix:///path instead of making an HTTP request to HTTPURL.
+	FastCGIAddress        string
+	FastCGIScriptFilename string
+	FastCGIRequestURI     string
+	FastCGITimeout        time.Duration
+
+	// GRPCClient, when set, delivers each message by calling its Deliver method instead of making an HTTP
+	// request to HTTPURL.
+	GRPCClient  sqsdpb.WorkerClient
+	GRPCTimeout time.Duration
+
+	// PeriodicTasksURL is the base URL the paths of periodic task messages are appended to. It defaults to
+	// HTTPURL.
+	PeriodicTasksURL string
+
+	// DeadLetterQueueURL is the queue messages are moved to when a target asks for them to be dead-lettered.
+	DeadLetterQueueURL string
+}
+
+// TokenSource provides access tokens, such as oauth.ClientCredentials. Invalidate is called with a token
+// that was rejected, so that it is not handed out again.
+type TokenSource interface {
+	Token() (string, error)
+	Invalidate(token string)
 }
 
 type httpClient interface {
@@ -55,13 +115,41 @@ type httpClient interface {
 }
 
 func NewSupervisor(logger *log.Entry, sqs sqsiface.SQSAPI, httpClient httpClient, config WorkerConfig) *Supervisor {
-	return &Supervisor{
+	s := &Supervisor{
 		logger:        logger,
 		sqs:           sqs,
-		httpClient:    httpClient,
 		workerConfig:  config,
+		httpClient:    httpClient,
 		hmacSignature: fmt.Sprintf("POST %s\n", config.HTTPURL),
+		queueARN:      queueARN(config.QueueRegion, config.QueueURL),
 	}
+
+	if len(config.ExecCommand) > 0 {
+		s.target = &execTarget{logger: logger, command: config.ExecCommand, timeout: config.ExecTimeout}
+	} else if config.GRPCClient != nil {
+		s.target = &grpcTarget{client: config.GRPCClient, timeout: config.GRPCTimeout}
+	} else if len(config.FastCGIAddress) > 0 {
+		s.target = newFCGITarget(config.FastCGIAddress, config.FastCGIScriptFilename, config.FastCGIRequestURI, config.FastCGITimeout)
+	} else {
+		s.target = &httpTarget{client: httpClient, url: config.HTTPURL}
+	}
+
+	return s
+}
+
+// queueARN derives the ARN of a queue from its region and URL, which is made up of the account id and queue name.
+func queueARN(region string, queueURL string) string {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 2 {
+		return ""
+	}
+
+	return fmt.Sprintf("arn:aws:sqs:%s:%s:%s", region, parts[0], parts[1])
 }
 
 func (s *Supervisor) Start(numWorkers int) {
@@ -100,6 +188,7 @@ func (s *Supervisor) worker() {
 			QueueUrl:              aws.String(s.workerConfig.QueueURL),
 			WaitTimeSeconds:       aws.Int64(int64(s.workerConfig.QueueWaitTime)),
 			MessageAttributeNames: aws.StringSlice([]string{"All"}),
+			AttributeNames:        aws.StringSlice([]string{"All"}),
 		}
 
 		output, err := s.sqs.ReceiveMessage(recInput)
@@ -112,61 +201,30 @@ func (s *Supervisor) worker() {
 			continue
 		}
 
-		deleteEntries := make([]*sqs.DeleteMessageBatchRequestEntry, 0)
-		changeVisibilityEntries := make([]*sqs.ChangeMessageVisibilityBatchRequestEntry, 0)
-
-		for _, msg := range output.Messages {
-			res, err := s.httpRequest(msg)
-			if err != nil {
-				s.logger.Errorf("Error making HTTP request: %s", err)
-				continue
-			}
-
-			if res.StatusCode < http.StatusOK || res.StatusCode > http.StatusIMUsed {
-
-				if res.StatusCode == http.StatusTooManyRequests {
-					sec, err := getRetryAfterFromResponse(res)
-					if err != nil {
-						s.logger.Errorf("Error getting retry after value from HTTP response: %s", err)
-						continue
-					}
-
-					changeVisibilityEntries = append(changeVisibilityEntries, &sqs.ChangeMessageVisibilityBatchRequestEntry{
-						Id:                msg.MessageId,
-						ReceiptHandle:     msg.ReceiptHandle,
-						VisibilityTimeout: aws.Int64(sec),
-					})
-				}
-
-				s.logger.Errorf("Non-successful status code: %d", res.StatusCode)
-
-				continue
-
-			}
-
-			deleteEntries = append(deleteEntries, &sqs.DeleteMessageBatchRequestEntry{
-				Id:            msg.MessageId,
-				ReceiptHandle: msg.ReceiptHandle,
-			})
-
-			s.logger.Debugf("Message %s successfully processed", *msg.MessageId)
+		var p *processed
+		if s.workerConfig.BatchDelivery {
+			p = s.processBatch(output.Messages)
+		} else {
+			p = s.processMessages(output.Messages)
 		}
 
-		if len(deleteEntries) > 0 {
+		if len(p.deleteEntries) > 0 {
 			delInput := &sqs.DeleteMessageBatchInput{
-				Entries:  deleteEntries,
+				Entries:  p.deleteEntries,
 				QueueUrl: aws.String(s.workerConfig.QueueURL),
 			}
 
-			_, err = s.sqs.DeleteMessageBatch(delInput)
+			delOutput, err := s.sqs.DeleteMessageBatch(delInput)
 			if err != nil {
 				s.logger.Errorf("Error while deleting messages from SQS: %s", err)
+			} else {
+				s.deletePayloads(p.payloadPointers, delOutput)
 			}
 		}
 
-		if len(changeVisibilityEntries) > 0 {
+		if len(p.changeVisibilityEntries) > 0 {
 			changeVisibilityInput := &sqs.ChangeMessageVisibilityBatchInput{
-				Entries:  changeVisibilityEntries,
+				Entries:  p.changeVisibilityEntries,
 				QueueUrl: aws.String(s.workerConfig.QueueURL),
 			}
 
@@ -178,53 +236,221 @@ func (s *Supervisor) worker() {
 	}
 }
 
-func (s *Supervisor) httpRequest(msg *sqs.Message) (*http.Response, error) {
-	body := *msg.Body
-	req, err := http.NewRequest("POST", s.workerConfig.HTTPURL, bytes.NewBufferString(body))
-	req.Header.Add("X-Aws-Sqsd-Msgid", *msg.MessageId)
-	s.addMessageAttributesToHeader(msg.MessageAttributes, req.Header)
+// processed collects the outcome of delivering a batch of received messages.
+type processed struct {
+	deleteEntries           []*sqs.DeleteMessageBatchRequestEntry
+	changeVisibilityEntries []*sqs.ChangeMessageVisibilityBatchRequestEntry
+	payloadPointers         map[string]*payloadS3Pointer
+}
+
+func newProcessed() *processed {
+	return &processed{
+		deleteEntries:           make([]*sqs.DeleteMessageBatchRequestEntry, 0),
+		changeVisibilityEntries: make([]*sqs.ChangeMessageVisibilityBatchRequestEntry, 0),
+		payloadPointers:         make(map[string]*payloadS3Pointer),
+	}
+}
+
+func (p *processed) delete(msg *sqs.Message, pointer *payloadS3Pointer) {
+	p.deleteEntries = append(p.deleteEntries, &sqs.DeleteMessageBatchRequestEntry{
+		Id:            msg.MessageId,
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+
+	if pointer != nil {
+		p.payloadPointers[*msg.MessageId] = pointer
+	}
+}
+
+func (p *processed) changeVisibility(msg *sqs.Message, sec int64) {
+	p.changeVisibilityEntries = append(p.changeVisibilityEntries, &sqs.ChangeMessageVisibilityBatchRequestEntry{
+		Id:                msg.MessageId,
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: aws.Int64(sec),
+	})
+}
+
+func (s *Supervisor) processMessages(msgs []*sqs.Message) *processed {
+	p := newProcessed()
+
+	for _, msg := range msgs {
+		s.processMessage(msg, p)
+	}
+
+	return p
+}
+
+func (s *Supervisor) processMessage(msg *sqs.Message, p *processed) {
+	body, pointer, err := s.messageBody(msg)
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating HTTP request: %s", err)
+		s.logger.Errorf("Error getting message body: %s", err)
+		return
+	}
+
+	res, err := s.deliver(msg, body)
+	if err != nil {
+		s.logger.Errorf("Error delivering message: %s", err)
+		return
+	}
+
+	if !s.handleResponse(res, []*sqs.Message{msg}, p) {
+		return
 	}
 
-	if len(s.workerConfig.HMACSecretKey) > 0 {
-		hmac, err := makeHMAC(strings.Join([]string{s.hmacSignature, body}, ""), s.workerConfig.HMACSecretKey)
+	p.delete(msg, pointer)
+
+	s.logger.Debugf("Message %s successfully processed", *msg.MessageId)
+}
+
+// handleResponse reports whether res was successful. When the service asks for messages to be retried
+// later or dead-lettered, msgs are handled accordingly.
+func (s *Supervisor) handleResponse(res *response, msgs []*sqs.Message, p *processed) bool {
+	if res.successful() {
+		return true
+	}
+
+	if res.deadLetter {
+		s.deadLetter(msgs, p)
+		return false
+	}
+
+	if res.statusCode == http.StatusTooManyRequests {
+		sec, err := getRetryAfterFromResponse(res)
+		if err != nil {
+			s.logger.Errorf("Error getting retry after value from HTTP response: %s", err)
+			return false
+		}
+
+		for _, msg := range msgs {
+			p.changeVisibility(msg, sec)
+		}
+	}
+
+	s.logger.Errorf("Non-successful status code: %d", res.statusCode)
+
+	return false
+}
+
+// deliver makes one request for each delivery decoded from msg, stopping at the first failure.
+func (s *Supervisor) deliver(msg *sqs.Message, body string) (*response, error) {
+	if task, ok := periodicTaskFromMessage(msg); ok {
+		return s.deliverPeriodicTask(msg, task, body)
+	}
+
+	var res *response
+
+	for _, d := range decodeEnvelopes(s.workerConfig.EnvelopeDecoders, body) {
+		header := http.Header{}
+		header.Add("X-Aws-Sqsd-Msgid", *msg.MessageId)
+		s.addMessageAttributesToHeader(msg.MessageAttributes, header)
+
+		for k, v := range d.Header {
+			header[k] = v
+		}
+
+		body, err := s.encode(msg, d, header)
+		if err != nil {
+			return nil, fmt.Errorf("Error while encoding message: %s", err)
+		}
+
+		res, err = s.request(body, header)
 		if err != nil {
 			return nil, err
 		}
 
-		req.Header.Set(s.workerConfig.HTTPHMACHeader, hmac)
+		if !res.successful() {
+			return res, nil
+		}
 	}
 
-	if len(s.workerConfig.HTTPAUTHORIZATIONHeader) > 0 {
-		headerName := s.workerConfig.HTTPAUTHORIZATIONHeaderName
-		if len(headerName) == 0 {
-			headerName = "Authorization"
+	return res, nil
+}
+
+// request adds the configured headers to header and sends body to the target.
+func (s *Supervisor) request(body string, header http.Header) (*response, error) {
+	return s.requestTarget(s.target, s.hmacSignature, body, header)
+}
+
+// requestTarget sends body to t, signing it with hmacSignature as the request line.
+func (s *Supervisor) requestTarget(t target, hmacSignature string, body string, header http.Header) (*response, error) {
+	if secretKey := s.hmacSecretKey(); len(secretKey) > 0 {
+		hmac, err := makeHMAC(strings.Join([]string{hmacSignature, body}, ""), secretKey)
+		if err != nil {
+			return nil, err
 		}
-		req.Header.Set(headerName, s.workerConfig.HTTPAUTHORIZATIONHeader)
+
+		header.Set(s.workerConfig.HTTPHMACHeader, hmac)
 	}
 
-	if len(s.workerConfig.HTTPContentType) > 0 {
-		req.Header.Set("Content-Type", s.workerConfig.HTTPContentType)
+	authorization, err := s.authorizationHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(authorization) > 0 {
+		header.Set(s.authorizationHeaderName(), authorization)
+	}
+
+	if len(s.workerConfig.HTTPContentType) > 0 && len(header.Get("Content-Type")) == 0 {
+		header.Set("Content-Type", s.workerConfig.HTTPContentType)
 	}
 
 	if len(s.workerConfig.UserAgent) > 0 {
-		req.Header.Set("User-Agent", s.workerConfig.UserAgent)
+		header.Set("User-Agent", s.workerConfig.UserAgent)
 	}
 
-	res, err := s.httpClient.Do(req)
-	if err != nil {
+	res, err := t.send(body, header)
+	if err != nil || res.statusCode != http.StatusUnauthorized || s.workerConfig.TokenSource == nil {
 		return res, err
 	}
 
-	res.Body.Close()
+	// The token may have been revoked or expired early, so fetch a new one and try again.
+	s.workerConfig.TokenSource.Invalidate(strings.TrimPrefix(authorization, "Bearer "))
 
-	return res, nil
+	if authorization, err = s.authorizationHeader(); err != nil {
+		return nil, err
+	}
+	header.Set(s.authorizationHeaderName(), authorization)
+
+	return t.send(body, header)
+}
+
+func (s *Supervisor) hmacSecretKey() []byte {
+	if s.workerConfig.HMACSecretKeyFile != nil {
+		return s.workerConfig.HMACSecretKeyFile.Bytes()
+	}
+
+	return s.workerConfig.HMACSecretKey
+}
+
+func (s *Supervisor) authorizationHeader() (string, error) {
+	if s.workerConfig.TokenSource != nil {
+		token, err := s.workerConfig.TokenSource.Token()
+		if err != nil {
+			return "", fmt.Errorf("Error while fetching access token: %s", err)
+		}
+
+		return "Bearer " + token, nil
+	}
+
+	if s.workerConfig.HTTPAUTHORIZATIONHeaderFile != nil {
+		return s.workerConfig.HTTPAUTHORIZATIONHeaderFile.String(), nil
+	}
+
+	return s.workerConfig.HTTPAUTHORIZATIONHeader, nil
+}
+
+func (s *Supervisor) authorizationHeaderName() string {
+	if len(s.workerConfig.HTTPAUTHORIZATIONHeaderName) > 0 {
+		return s.workerConfig.HTTPAUTHORIZATIONHeaderName
+	}
+
+	return "Authorization"
 }
 
 func (s *Supervisor) addMessageAttributesToHeader(attrs map[string]*sqs.MessageAttributeValue, header http.Header) {
 	for k, v := range attrs {
-		header.Add("X-Aws-Sqsd-Attr-" + k, *v.StringValue)
+		header.Add("X-Aws-Sqsd-Attr-"+k, aws.StringValue(v.StringValue))
 	}
 }
 
@@ -239,8 +465,8 @@ func makeHMAC(signature string, secretKey []byte) (string, error) {
 	return hex.EncodeToString(mac.Sum(nil)), nil
 }
 
-func getRetryAfterFromResponse(res *http.Response) (int64, error) {
-	retryAfter := res.Header.Get("Retry-After")
+func getRetryAfterFromResponse(res *response) (int64, error) {
+	retryAfter := res.header.Get("Retry-After")
 	if len(retryAfter) == 0 {
 		return 0, errors.New("Retry-After header value is empty")
 	}
@@ -1,21 +1,33 @@
 package supervisor
 
 import (
+	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/fcgi"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/fterrag/simple-sqsd/sqsdpb"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 type mockSQS struct {
@@ -24,6 +36,7 @@ type mockSQS struct {
 	receiveMessageFunc               func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error)
 	deleteMessageBatchFunc           func(*sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error)
 	changeMessageVisibilityBatchFunc func(*sqs.ChangeMessageVisibilityBatchInput) (*sqs.ChangeMessageVisibilityBatchOutput, error)
+	sendMessageFunc                  func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error)
 }
 
 func (m *mockSQS) ReceiveMessage(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
@@ -50,6 +63,51 @@ func (m *mockSQS) ChangeMessageVisibilityBatch(input *sqs.ChangeMessageVisibilit
 	return nil, nil
 }
 
+func (m *mockSQS) SendMessage(input *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	if m.sendMessageFunc != nil {
+		return m.sendMessageFunc(input)
+	}
+
+	return nil, nil
+}
+
+type mockS3 struct {
+	s3iface.S3API
+
+	getObjectFunc    func(*s3.GetObjectInput) (*s3.GetObjectOutput, error)
+	deleteObjectFunc func(*s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error)
+}
+
+func (m *mockS3) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if m.getObjectFunc != nil {
+		return m.getObjectFunc(input)
+	}
+
+	return nil, nil
+}
+
+func (m *mockS3) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	if m.deleteObjectFunc != nil {
+		return m.deleteObjectFunc(input)
+	}
+
+	return nil, nil
+}
+
+type mockTokenSource struct {
+	tokens []string
+}
+
+func (m *mockTokenSource) Token() (string, error) {
+	return m.tokens[0], nil
+}
+
+func (m *mockTokenSource) Invalidate(token string) {
+	if token == m.tokens[0] {
+		m.tokens = m.tokens[1:]
+	}
+}
+
 func TestSupervisorSuccess(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
@@ -215,6 +273,60 @@ func TestSupervisorHMAC(t *testing.T) {
 	assert.True(t, hmacSuccess)
 }
 
+func TestSupervisorTokenSourceRefresh(t *testing.T) {
+	var authorizations []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	config := WorkerConfig{
+		HTTPURL:     ts.URL,
+		TokenSource: &mockTokenSource{tokens: []string{"revoked", "fresh"}},
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, &http.Client{}, config)
+
+	mockSQS.receiveMessageFunc = func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body:          aws.String("message 1"),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+			}},
+		}, nil
+	}
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		defer supervisor.Shutdown()
+
+		assert.Len(t, input.Entries, 1)
+
+		return nil, nil
+	}
+
+	mockSQS.changeMessageVisibilityBatchFunc = func(input *sqs.ChangeMessageVisibilityBatchInput) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+		assert.Fail(t, "ChangeMessageVisibilityBatchFunc was called")
+		return nil, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+
+	assert.Equal(t, []string{"Bearer revoked", "Bearer fresh"}, authorizations)
+}
+
 func TestSupervisorTooManyRequests(t *testing.T) {
 	delayTime := time.Duration(1 * time.Hour)
 	requestCount := 0
@@ -338,3 +450,777 @@ func TestSupervisorTooManyRequestsBadRetryAfter(t *testing.T) {
 	supervisor.Start(1)
 	supervisor.Wait()
 }
+
+func TestSupervisorUnwrapSNS(t *testing.T) {
+	var body string
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+
+		body = string(b)
+		header = r.Header
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	config := WorkerConfig{
+		HTTPURL:          ts.URL,
+		EnvelopeDecoders: []EnvelopeDecoder{SNSEnvelopeDecoder{}},
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, &http.Client{}, config)
+
+	mockSQS.receiveMessageFunc = func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body: aws.String(`{
+					"Type": "Notification",
+					"MessageId": "sns1",
+					"TopicArn": "arn:aws:sns:us-east-1:123456789012:topic",
+					"Subject": "greeting",
+					"Message": "{\"hello\":\"world\"}",
+					"MessageAttributes": {"foo": {"Type": "String", "Value": "bar"}}
+				}`),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+			}},
+		}, nil
+	}
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		defer supervisor.Shutdown()
+
+		assert.Len(t, input.Entries, 1)
+
+		return nil, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+
+	assert.Equal(t, `{"hello":"world"}`, body)
+	assert.Equal(t, "arn:aws:sns:us-east-1:123456789012:topic", header.Get("X-Aws-Sns-Topic-Arn"))
+	assert.Equal(t, "greeting", header.Get("X-Aws-Sns-Subject"))
+	assert.Equal(t, "bar", header.Get("X-Aws-Sns-Attr-Foo"))
+	assert.Equal(t, "m1", header.Get("X-Aws-Sqsd-Msgid"))
+}
+
+func TestSupervisorEnvelopeFanOutFailure(t *testing.T) {
+	requestCount := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+
+		if r.Header.Get("X-Aws-S3-Key") == "a.txt" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	config := WorkerConfig{
+		HTTPURL:          ts.URL,
+		EnvelopeDecoders: []EnvelopeDecoder{S3EventEnvelopeDecoder{}},
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, &http.Client{}, config)
+
+	mockSQS.receiveMessageFunc = func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		defer supervisor.Shutdown()
+
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body:          aws.String(s3EventBody),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+			}},
+		}, nil
+	}
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		assert.Fail(t, "DeleteMessageBatchInput was called")
+		return nil, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+
+	assert.Equal(t, 1, requestCount)
+}
+
+func TestSupervisorExtendedPayload(t *testing.T) {
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+
+		body = string(b)
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	mockS3 := &mockS3{}
+	config := WorkerConfig{
+		HTTPURL: ts.URL,
+
+		PayloadS3:            mockS3,
+		DeletePayloadsFromS3: true,
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, &http.Client{}, config)
+
+	mockSQS.receiveMessageFunc = func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body:          aws.String(`["software.amazon.payloadoffloading.PayloadS3Pointer",{"s3BucketName":"bucket","s3Key":"key"}]`),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+			}},
+		}, nil
+	}
+
+	mockS3.getObjectFunc = func(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+		assert.Equal(t, "bucket", *input.Bucket)
+		assert.Equal(t, "key", *input.Key)
+
+		return &s3.GetObjectOutput{
+			Body: ioutil.NopCloser(strings.NewReader("large payload")),
+		}, nil
+	}
+
+	deletedObject := false
+	mockS3.deleteObjectFunc = func(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+		assert.Equal(t, "bucket", *input.Bucket)
+		assert.Equal(t, "key", *input.Key)
+
+		deletedObject = true
+
+		return nil, nil
+	}
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		defer supervisor.Shutdown()
+
+		assert.Len(t, input.Entries, 1)
+
+		return &sqs.DeleteMessageBatchOutput{
+			Successful: []*sqs.DeleteMessageBatchResultEntry{{Id: aws.String("m1")}},
+		}, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+
+	assert.Equal(t, "large payload", body)
+	assert.True(t, deletedObject)
+}
+
+func TestSupervisorBatchDelivery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		b, _ := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+
+		assert.JSONEq(t, `[
+			{"id": "m1", "body": "message 1", "attributes": {"foo": "bar"}},
+			{"id": "m2", "body": "message 2", "attributes": {}},
+			{"id": "m3", "body": "message 3", "attributes": {}}
+		]`, string(b))
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"batchItemFailures": [{"itemIdentifier": "m2"}]}`))
+	}))
+	defer ts.Close()
+
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	config := WorkerConfig{
+		HTTPURL:       ts.URL,
+		BatchDelivery: true,
+	}
+
+	mockSQS.receiveMessageFunc = func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body:          aws.String("message 1"),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+				MessageAttributes: map[string]*sqs.MessageAttributeValue{
+					"foo": {DataType: aws.String("String"), StringValue: aws.String("bar")},
+				},
+			}, {
+				Body:          aws.String("message 2"),
+				MessageId:     aws.String("m2"),
+				ReceiptHandle: aws.String("r2"),
+			}, {
+				Body:          aws.String("message 3"),
+				MessageId:     aws.String("m3"),
+				ReceiptHandle: aws.String("r3"),
+			}},
+		}, nil
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, &http.Client{}, config)
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		defer supervisor.Shutdown()
+
+		assert.Len(t, input.Entries, 2)
+		assert.Equal(t, "m1", *input.Entries[0].Id)
+		assert.Equal(t, "m3", *input.Entries[1].Id)
+
+		return nil, nil
+	}
+
+	mockSQS.changeMessageVisibilityBatchFunc = func(input *sqs.ChangeMessageVisibilityBatchInput) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+		assert.Fail(t, "ChangeMessageVisibilityBatchFunc was called")
+		return nil, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+}
+
+func TestSupervisorLambdaPayloadFormat(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		b, _ := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+
+		assert.JSONEq(t, `{"Records": [{
+			"messageId": "m1",
+			"receiptHandle": "r1",
+			"body": "test",
+			"attributes": {"ApproximateReceiveCount": "1"},
+			"messageAttributes": {"foo": {"stringValue": "bar", "stringListValues": [], "binaryListValues": [], "dataType": "String"}},
+			"md5OfBody": "098f6bcd4621d373cade4e832627b4f6",
+			"eventSource": "aws:sqs",
+			"eventSourceARN": "arn:aws:sqs:us-east-1:123456789012:queue",
+			"awsRegion": "us-east-1"
+		}]}`, string(b))
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	config := WorkerConfig{
+		QueueRegion: "us-east-1",
+		QueueURL:    "https://sqs.us-east-1.amazonaws.com/123456789012/queue",
+
+		HTTPURL:       ts.URL,
+		PayloadFormat: PayloadFormatLambda,
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, &http.Client{}, config)
+
+	mockSQS.receiveMessageFunc = func(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body:          aws.String("test"),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+				Attributes: map[string]*string{
+					"ApproximateReceiveCount": aws.String("1"),
+				},
+				MessageAttributes: map[string]*sqs.MessageAttributeValue{
+					"foo": {DataType: aws.String("String"), StringValue: aws.String("bar")},
+				},
+			}},
+		}, nil
+	}
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		defer supervisor.Shutdown()
+
+		assert.Len(t, input.Entries, 1)
+
+		return nil, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+}
+
+func TestSupervisorCloudEventsBinaryPayloadFormat(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "1.0", r.Header.Get("Ce-Specversion"))
+		assert.Equal(t, "m1", r.Header.Get("Ce-Id"))
+		assert.Equal(t, "arn:aws:sqs:us-east-1:123456789012:queue", r.Header.Get("Ce-Source"))
+		assert.Equal(t, "com.example.order.placed", r.Header.Get("Ce-Type"))
+		assert.Equal(t, "2018-12-17T21:37:29.183Z", r.Header.Get("Ce-Time"))
+
+		b, _ := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+
+		assert.Equal(t, `{"orderId":1}`, string(b))
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	config := WorkerConfig{
+		QueueRegion: "us-east-1",
+		QueueURL:    "https://sqs.us-east-1.amazonaws.com/123456789012/queue",
+
+		HTTPURL:         ts.URL,
+		HTTPContentType: "application/json",
+		PayloadFormat:   PayloadFormatCloudEventsBinary,
+
+		CloudEventsTypeAttribute: "type",
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, &http.Client{}, config)
+
+	mockSQS.receiveMessageFunc = func(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body:          aws.String(`{"orderId":1}`),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+				Attributes: map[string]*string{
+					"SentTimestamp": aws.String("1545082649183"),
+				},
+				MessageAttributes: map[string]*sqs.MessageAttributeValue{
+					"type": {DataType: aws.String("String"), StringValue: aws.String("com.example.order.placed")},
+				},
+			}},
+		}, nil
+	}
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		defer supervisor.Shutdown()
+
+		assert.Len(t, input.Entries, 1)
+
+		return nil, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+}
+
+func TestSupervisorCloudEventsStructuredPayloadFormat(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/cloudevents+json", r.Header.Get("Content-Type"))
+
+		b, _ := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+
+		assert.JSONEq(t, `{
+			"specversion": "1.0",
+			"id": "m1",
+			"source": "arn:aws:sqs:us-east-1:123456789012:queue",
+			"type": "com.amazonaws.sqs.message",
+			"data": "plain text"
+		}`, string(b))
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	config := WorkerConfig{
+		QueueRegion: "us-east-1",
+		QueueURL:    "https://sqs.us-east-1.amazonaws.com/123456789012/queue",
+
+		HTTPURL:       ts.URL,
+		PayloadFormat: PayloadFormatCloudEventsStructured,
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, &http.Client{}, config)
+
+	mockSQS.receiveMessageFunc = func(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body:          aws.String("plain text"),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+			}},
+		}, nil
+	}
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		defer supervisor.Shutdown()
+
+		assert.Len(t, input.Entries, 1)
+
+		return nil, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+}
+
+func TestSupervisorPeriodicTask(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/tasks/cleanup", r.URL.Path)
+		assert.Equal(t, "cleanup", r.Header.Get("X-Aws-Sqsd-Taskname"))
+		assert.Equal(t, "2021-01-01T00:00:00Z", r.Header.Get("X-Aws-Sqsd-Scheduled-At"))
+
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, PeriodicTaskBody, string(body))
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	config := WorkerConfig{
+		HTTPURL:          ts.URL + "/messages",
+		PeriodicTasksURL: ts.URL,
+		BatchDelivery:    true,
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, &http.Client{}, config)
+
+	mockSQS.receiveMessageFunc = func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body:          aws.String(PeriodicTaskBody),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+				MessageAttributes: map[string]*sqs.MessageAttributeValue{
+					PeriodicTaskNameAttribute:        {DataType: aws.String("String"), StringValue: aws.String("cleanup")},
+					PeriodicTaskPathAttribute:        {DataType: aws.String("String"), StringValue: aws.String("/tasks/cleanup")},
+					PeriodicTaskScheduledAtAttribute: {DataType: aws.String("String"), StringValue: aws.String("2021-01-01T00:00:00Z")},
+				},
+			}},
+		}, nil
+	}
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		defer supervisor.Shutdown()
+
+		assert.Len(t, input.Entries, 1)
+
+		return nil, nil
+	}
+
+	mockSQS.changeMessageVisibilityBatchFunc = func(input *sqs.ChangeMessageVisibilityBatchInput) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+		assert.Fail(t, "ChangeMessageVisibilityBatchFunc was called")
+		return nil, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+}
+
+func TestSupervisorExec(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	config := WorkerConfig{
+		ExecCommand: []string{"sh", "-c", `test "$(cat)" = "message 1" && test "$X_AWS_SQSD_MSGID" = "m1" && test "$X_AWS_SQSD_ATTR_FOO" = "bar"`},
+		ExecTimeout: 5 * time.Second,
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, nil, config)
+
+	mockSQS.receiveMessageFunc = func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body:          aws.String("message 1"),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+				MessageAttributes: map[string]*sqs.MessageAttributeValue{
+					"foo": {DataType: aws.String("String"), StringValue: aws.String("bar")},
+				},
+			}, {
+				Body:          aws.String("message 2"),
+				MessageId:     aws.String("m2"),
+				ReceiptHandle: aws.String("r2"),
+			}},
+		}, nil
+	}
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		defer supervisor.Shutdown()
+
+		assert.Len(t, input.Entries, 1)
+		assert.Equal(t, "m1", *input.Entries[0].Id)
+
+		return nil, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+}
+
+func TestExecTargetTimeout(t *testing.T) {
+	target := &execTarget{
+		logger:  log.WithFields(log.Fields{}),
+		command: []string{"sh", "-c", "sleep 5; echo done"},
+		timeout: 500 * time.Millisecond,
+	}
+
+	start := time.Now()
+	resp, err := target.send("", http.Header{})
+
+	assert.Nil(t, resp)
+	assert.EqualError(t, err, "Command timed out after 500ms: ")
+	assert.True(t, time.Since(start) < 3*time.Second, "command ran for %s", time.Since(start))
+}
+
+func TestSupervisorFastCGI(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	go fcgi.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/worker", r.URL.Path)
+		assert.Equal(t, "/var/www/index.php", fcgi.ProcessEnv(r)["SCRIPT_FILENAME"])
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		b, _ := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+
+		assert.Equal(t, r.Header.Get("X-Aws-Sqsd-Msgid"), string(b))
+
+		if r.Header.Get("X-Aws-Sqsd-Msgid") == "m2" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	config := WorkerConfig{
+		HTTPContentType: "application/json",
+
+		FastCGIAddress:        "tcp://" + l.Addr().String(),
+		FastCGIScriptFilename: "/var/www/index.php",
+		FastCGIRequestURI:     "/worker",
+		FastCGITimeout:        5 * time.Second,
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, nil, config)
+
+	mockSQS.receiveMessageFunc = func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body:          aws.String("m1"),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+			}, {
+				Body:          aws.String("m2"),
+				MessageId:     aws.String("m2"),
+				ReceiptHandle: aws.String("r2"),
+			}},
+		}, nil
+	}
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		defer supervisor.Shutdown()
+
+		assert.Len(t, input.Entries, 1)
+		assert.Equal(t, "m1", *input.Entries[0].Id)
+
+		return nil, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+}
+
+// serveRawFCGI answers a single FastCGI request on l with stdout and an end request record with the given
+// statuses, the way a misbehaving or overloaded responder would.
+func serveRawFCGI(t *testing.T, l net.Listener, stdout []byte, appStatus uint32, protocolStatus uint8) {
+	conn, err := l.Accept()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		h := fcgiHeader{}
+		if !assert.NoError(t, binary.Read(conn, binary.BigEndian, &h)) {
+			return
+		}
+		content := make([]byte, int(h.ContentLength)+int(h.PaddingLength))
+		if _, err := io.ReadFull(conn, content); !assert.NoError(t, err) {
+			return
+		}
+		if h.Type == fcgiStdin && h.ContentLength == 0 {
+			break
+		}
+	}
+
+	if len(stdout) > 0 {
+		assert.NoError(t, writeFCGIRecord(conn, fcgiStdout, stdout))
+	}
+	assert.NoError(t, writeFCGIRecord(conn, fcgiStdout, nil))
+
+	var end bytes.Buffer
+	binary.Write(&end, binary.BigEndian, fcgiEndRequestBody{AppStatus: appStatus, ProtocolStatus: protocolStatus})
+	assert.NoError(t, writeFCGIRecord(conn, fcgiEndRequest, end.Bytes()))
+}
+
+func TestFCGITargetResponses(t *testing.T) {
+	tests := []struct {
+		name           string
+		stdout         string
+		appStatus      uint32
+		protocolStatus uint8
+		statusCode     int
+		err            string
+	}{
+		{name: "status", stdout: "Status: 202 Accepted\r\n\r\n", statusCode: http.StatusAccepted},
+		{name: "no status", stdout: "Content-Type: text/html\r\n\r\nok", statusCode: http.StatusOK},
+		{name: "empty", err: "Error while parsing FastCGI response: response has no CGI headers: "},
+		{name: "no headers", stdout: "\r\nok", err: "Error while parsing FastCGI response: response has no CGI headers: "},
+		{name: "overloaded", protocolStatus: fcgiOverloaded, err: "Error while reading FastCGI response: request rejected: overloaded"},
+		{name: "unknown role", protocolStatus: fcgiUnknownRole, err: "Error while reading FastCGI response: request rejected: unknown role"},
+		{name: "app status", stdout: "Status: 200\r\n\r\n", appStatus: 255, err: "Error while reading FastCGI response: request ended with application status 255: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			defer l.Close()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				serveRawFCGI(t, l, []byte(tt.stdout), tt.appStatus, tt.protocolStatus)
+			}()
+
+			target := newFCGITarget(l.Addr().String(), "/var/www/index.php", "/worker", 5*time.Second)
+			res, err := target.send("message", http.Header{})
+			<-done
+
+			if tt.err != "" {
+				assert.Nil(t, res)
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.statusCode, res.statusCode)
+		})
+	}
+}
+
+type mockWorkerServer struct {
+	sqsdpb.UnimplementedWorkerServer
+
+	deliverFunc func(context.Context, *sqsdpb.Message) (*sqsdpb.Result, error)
+}
+
+func (m *mockWorkerServer) Deliver(ctx context.Context, msg *sqsdpb.Message) (*sqsdpb.Result, error) {
+	return m.deliverFunc(ctx, msg)
+}
+
+func TestSupervisorGRPC(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	server := grpc.NewServer()
+	sqsdpb.RegisterWorkerServer(server, &mockWorkerServer{
+		deliverFunc: func(ctx context.Context, msg *sqsdpb.Message) (*sqsdpb.Result, error) {
+			assert.Equal(t, "message "+msg.Id, string(msg.Body))
+			assert.Equal(t, msg.Id, msg.Headers["X-Aws-Sqsd-Msgid"])
+
+			switch msg.Id {
+			case "m1":
+				return &sqsdpb.Result{Action: sqsdpb.Result_ACTION_DELETE}, nil
+			case "m2":
+				return &sqsdpb.Result{Action: sqsdpb.Result_ACTION_RETRY, RetryDelaySeconds: 30}, nil
+			}
+
+			return &sqsdpb.Result{Action: sqsdpb.Result_ACTION_DEAD_LETTER}, nil
+		},
+	})
+	go server.Serve(l)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	log.SetOutput(ioutil.Discard)
+	logger := log.WithFields(log.Fields{})
+	mockSQS := &mockSQS{}
+	config := WorkerConfig{
+		GRPCClient:  sqsdpb.NewWorkerClient(conn),
+		GRPCTimeout: 5 * time.Second,
+
+		DeadLetterQueueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/dlq",
+	}
+
+	supervisor := NewSupervisor(logger, mockSQS, nil, config)
+
+	mockSQS.receiveMessageFunc = func(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{{
+				Body:          aws.String("message m1"),
+				MessageId:     aws.String("m1"),
+				ReceiptHandle: aws.String("r1"),
+			}, {
+				Body:          aws.String("message m2"),
+				MessageId:     aws.String("m2"),
+				ReceiptHandle: aws.String("r2"),
+			}, {
+				Body:          aws.String("message m3"),
+				MessageId:     aws.String("m3"),
+				ReceiptHandle: aws.String("r3"),
+			}},
+		}, nil
+	}
+
+	mockSQS.sendMessageFunc = func(input *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		assert.Equal(t, "https://sqs.us-east-1.amazonaws.com/123456789012/dlq", *input.QueueUrl)
+		assert.Equal(t, "message m3", *input.MessageBody)
+
+		return &sqs.SendMessageOutput{}, nil
+	}
+
+	mockSQS.deleteMessageBatchFunc = func(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		assert.Len(t, input.Entries, 2)
+		assert.Equal(t, "m1", *input.Entries[0].Id)
+		assert.Equal(t, "m3", *input.Entries[1].Id)
+
+		return nil, nil
+	}
+
+	mockSQS.changeMessageVisibilityBatchFunc = func(input *sqs.ChangeMessageVisibilityBatchInput) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+		defer supervisor.Shutdown()
+
+		assert.Len(t, input.Entries, 1)
+		assert.Equal(t, "m2", *input.Entries[0].Id)
+		assert.Equal(t, int64(30), *input.Entries[0].VisibilityTimeout)
+
+		return nil, nil
+	}
+
+	supervisor.Start(1)
+	supervisor.Wait()
+}
@@ -0,0 +1,57 @@
+package supervisor
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// response is the outcome of sending a request to a target, expressed with HTTP semantics.
+type response struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+
+	// deadLetter is set when the target asked for the message to be moved to the dead-letter queue.
+	deadLetter bool
+}
+
+func (r *response) successful() bool {
+	return r.statusCode >= http.StatusOK && r.statusCode <= http.StatusIMUsed
+}
+
+// target sends request bodies to the service processing messages.
+type target interface {
+	send(body string, header http.Header) (*response, error)
+}
+
+// httpTarget POSTs request bodies to a URL.
+type httpTarget struct {
+	client httpClient
+	url    string
+}
+
+func (t *httpTarget) send(body string, header http.Header) (*response, error) {
+	req, err := http.NewRequest("POST", t.url, bytes.NewBufferString(body))
+	if err != nil {
+		return nil, fmt.Errorf("Error while creating HTTP request: %s", err)
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading HTTP response: %s", err)
+	}
+
+	return &response{statusCode: res.StatusCode, header: res.Header, body: resBody}, nil
+}